@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/api"
@@ -12,9 +16,14 @@ import (
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/db"
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/delivery"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/secrets"
 	"github.com/gin-gonic/gin"
 )
 
+// httpShutdownTimeout bounds how long the HTTP server may take to finish
+// its open requests once the deliveries have drained.
+const httpShutdownTimeout = 5 * time.Second
+
 func main() {
     r := gin.Default()
     if err := db.Init(); err != nil {
@@ -24,7 +33,14 @@ func main() {
     r.Static("/static", "./web/static")
     queries := database.New(db.DB)
     
+    // intake is closed on shutdown; /healthz then fails so load balancers
+    // stop routing here while deliveries drain.
+    intake := &api.IngestGate{}
     r.GET("/healthz", func(c *gin.Context) {
+        if intake.Closed() {
+            c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+            return
+        }
         c.JSON(http.StatusOK, gin.H{"status": "ok"})
     })
     r.GET("/", func(c *gin.Context) {
@@ -43,9 +59,38 @@ func main() {
         subCache = nil
     }   
 
+    secretBox, err := secrets.NewBoxFromEnv()
+    if err != nil {
+        log.Fatalf("invalid SECRETS_ENCRYPTION_KEY: %v", err)
+    }
+    if secretBox == nil {
+        log.Println("SECRETS_ENCRYPTION_KEY not set; mTLS client keys and outbound credentials cannot be stored")
+    }
+
+    targets, err := delivery.TargetPolicyFromEnv()
+    if err != nil {
+        log.Fatalf("invalid SSRF_ALLOWLIST: %v", err)
+    }
+
+    worker := delivery.NewWorker(queries, subCache)
+    worker.Secrets = secretBox
+    worker.Targets = targets
+
+    secretGracePeriod := 24 * time.Hour
+    if v := os.Getenv("SECRET_ROTATION_GRACE_PERIOD"); v != "" {
+        if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+            secretGracePeriod = d
+        } else {
+            log.Printf("Warning: invalid SECRET_ROTATION_GRACE_PERIOD '%s', using default %s", v, secretGracePeriod)
+        }
+    }
     subHandler := &api.SubscriptionHandler{
-        Queries: queries,
-        Cache:   subCache,
+        DB:                db.DB,
+        Queries:           queries,
+        Cache:             subCache,
+        Secrets:           secretBox,
+        Targets:           targets,
+        SecretGracePeriod: secretGracePeriod,
     }
 
     api.RegisterSubscriptionRoutes(r, subHandler)
@@ -53,34 +98,134 @@ func main() {
     analyticsHandler := &api.AnalyticsHandler{Queries: queries}
     api.RegisterAnalyticsRoutes(r, analyticsHandler)
 
-    webhookHandler := &api.WebhookHandler{Queries: queries, Cache: subCache}
+    idempotencyTTL := 24 * time.Hour
+    if v := os.Getenv("IDEMPOTENCY_KEY_TTL"); v != "" {
+        if d, err := time.ParseDuration(v); err == nil && d > 0 {
+            idempotencyTTL = d
+        } else {
+            log.Printf("Warning: invalid IDEMPOTENCY_KEY_TTL '%s', using default %s", v, idempotencyTTL)
+        }
+    }
+    timestampTolerance := 5 * time.Minute
+    if v := os.Getenv("INGEST_TIMESTAMP_TOLERANCE"); v != "" {
+        if d, err := time.ParseDuration(v); err == nil && d > 0 {
+            timestampTolerance = d
+        } else {
+            log.Printf("Warning: invalid INGEST_TIMESTAMP_TOLERANCE '%s', using default %s", v, timestampTolerance)
+        }
+    }
+    webhookHandler := &api.WebhookHandler{
+        DB:                 db.DB,
+        Queries:            queries,
+        Cache:              subCache,
+        Idempotency:        subCache.IdempotencyStore(idempotencyTTL),
+        Nonces:             subCache.NonceCache(),
+        TimestampTolerance: timestampTolerance,
+        Intake:             intake,
+    }
     api.RegisterWebhookRoutes(r, webhookHandler)
 
+    eventHandler := &api.EventHandler{
+        DB:           db.DB,
+        Queries:      queries,
+        Intake:       intake,
+        PublishToken: os.Getenv("EVENTS_PUBLISH_TOKEN"),
+    }
+    api.RegisterEventRoutes(r, eventHandler)
+
     dlqHandler := &api.DLQHandler{
         Queries: queries,
     }
     api.RegisterDLQRoutes(r, dlqHandler)
 
-    uiHandler := &api.UIHandler{Queries: queries, Cache: subCache}
+    uiHandler := &api.UIHandler{Queries: queries, Cache: subCache, Breakers: worker.Breakers, Targets: targets}
     api.RegisterUIRoutes(r, uiHandler)
 
+    breakerHandler := &api.CircuitBreakerHandler{Breakers: worker.Breakers}
+    api.RegisterCircuitBreakerRoutes(r, breakerHandler)
+
     scheduledHandler := &api.ScheduledHandler{Queries: queries}
     api.RegisterScheduledRoutes(r, scheduledHandler)
 
-    worker := delivery.NewWorker(queries, subCache)
-    go worker.Start(context.Background())
+    shutdownTimeout := 30 * time.Second
+    if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+        if d, err := time.ParseDuration(v); err == nil && d > 0 {
+            shutdownTimeout = d
+        } else {
+            log.Printf("Warning: invalid SHUTDOWN_TIMEOUT '%s', using default %s", v, shutdownTimeout)
+        }
+    }
 
-    cleanupWorker := delivery.NewCleanupWorker(queries)
-    go cleanupWorker.Start(context.Background())
-    
-    scheduledWorker := delivery.NewScheduledWorker(queries)
-    go scheduledWorker.Start(context.Background())
+    signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+    workerCtx, stopWorkers := context.WithCancel(context.Background())
+    var workers sync.WaitGroup
+    workers.Add(3)
+    go func() {
+        defer workers.Done()
+        worker.Start(workerCtx)
+    }()
 
+    cleanupWorker := delivery.NewCleanupWorker(queries)
+    go func() {
+        defer workers.Done()
+        cleanupWorker.Start(workerCtx)
+    }()
 
+    scheduledWorker := delivery.NewScheduledWorker(queries)
+    go func() {
+        defer workers.Done()
+        scheduledWorker.Start(workerCtx)
+    }()
 
     port := os.Getenv("PORT")
     if port == "" {
         port = "8080"
     }
-    r.Run(":" + port)
-}
\ No newline at end of file
+    srv := &http.Server{Addr: ":" + port, Handler: r}
+    go func() {
+        if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            log.Fatalf("HTTP server failed: %v", err)
+        }
+    }()
+
+    <-signals.Done()
+    // A second signal kills the process right away.
+    stopSignals()
+    log.Printf("Shutting down: draining in-flight deliveries for up to %s", shutdownTimeout)
+
+    // Take no new events, stop claiming tasks and wait for the deliveries
+    // already running to finish and be logged. Tasks still running at the
+    // deadline keep their lease and are retried once it expires.
+    intake.Close()
+    stopWorkers()
+    deadline, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+    defer cancel()
+    drained := make(chan struct{})
+    go func() {
+        workers.Wait()
+        close(drained)
+    }()
+    select {
+    case <-drained:
+        log.Println("In-flight deliveries drained")
+    case <-deadline.Done():
+        log.Println("Shutdown timeout reached with deliveries still in flight; they will be retried after their lease expires")
+    }
+
+    // The drain may have used up the whole timeout, so the HTTP server gets
+    // its own short one to finish the requests it is still answering.
+    httpDeadline, cancelHTTP := context.WithTimeout(context.Background(), httpShutdownTimeout)
+    defer cancelHTTP()
+    if err := srv.Shutdown(httpDeadline); err != nil {
+        log.Printf("HTTP server shutdown: %v", err)
+        srv.Close()
+    }
+    select {
+    case <-drained:
+        db.DB.Close()
+    default:
+        // Deliveries still running would fail to record their attempts on
+        // a closed database; exiting releases the connection anyway.
+    }
+    log.Println("Server stopped")
+}
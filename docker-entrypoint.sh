@@ -40,6 +40,6 @@ echo "Attempting to run migrations with connection string: $MASKED_CONN_STR"
 goose -dir ./migrations turso "$DB_CONN_STR" up
 echo "Migrations completed."
 
-# Start the app
+# Start the app; exec so it receives SIGTERM and can shut down gracefully
 echo "Starting webhook-delivery-service..."
-./webhook-delivery-service
\ No newline at end of file
+exec ./webhook-delivery-service
\ No newline at end of file
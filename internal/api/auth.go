@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/delivery"
+	"github.com/gin-gonic/gin"
+)
+
+// PutSubscriptionAuth handles PUT /subscriptions/:id/auth
+func (h *SubscriptionHandler) PutSubscriptionAuth(c *gin.Context) {
+    id := c.Param("id")
+    var req delivery.AuthConfig
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err := req.Validate(); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if req.Type == delivery.AuthOAuth2 {
+        if err := h.Targets.ValidateURL(req.TokenURL); err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "token_url: " + err.Error()})
+            return
+        }
+    }
+    if _, err := h.Queries.GetSubscription(c, id); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+        return
+    }
+
+    plaintext, err := json.Marshal(req)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    encrypted, err := h.Secrets.Encrypt(string(plaintext))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot store credentials: " + err.Error()})
+        return
+    }
+    err = h.Queries.UpdateSubscriptionAuth(c, database.UpdateSubscriptionAuthParams{
+        AuthType:            nullString(req.Type),
+        AuthConfigEncrypted: nullString(encrypted),
+        ID:                  id,
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if h.Cache != nil {
+        h.Cache.Del(id)
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// DeleteSubscriptionAuth handles DELETE /subscriptions/:id/auth
+func (h *SubscriptionHandler) DeleteSubscriptionAuth(c *gin.Context) {
+    id := c.Param("id")
+    err := h.Queries.UpdateSubscriptionAuth(c, database.UpdateSubscriptionAuthParams{ID: id})
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if h.Cache != nil {
+        h.Cache.Del(id)
+    }
+    c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/delivery"
+	"github.com/gin-gonic/gin"
+)
+
+type CircuitBreakerHandler struct {
+    Breakers *delivery.CircuitBreakers
+}
+
+func RegisterCircuitBreakerRoutes(r *gin.Engine, h *CircuitBreakerHandler) {
+    r.GET("/circuit-breakers", h.ListCircuitBreakers)
+}
+
+// ListCircuitBreakers handles GET /circuit-breakers
+// Breaker state is kept per instance, so this reports what this instance's
+// delivery worker currently sees.
+func (h *CircuitBreakerHandler) ListCircuitBreakers(c *gin.Context) {
+    c.JSON(http.StatusOK, h.Breakers.Snapshot())
+}
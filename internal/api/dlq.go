@@ -52,12 +52,20 @@ func (h *DLQHandler) RetryDLQTask(c *gin.Context) {
         c.String(http.StatusNotFound, "DLQ task not found")
         return
     }
-    // Requeue as a delivery task
+    // Requeue as a delivery task, at the back of the original task's stream
+    var orderingKey, eventID sql.NullString
+    if original, err := h.Queries.GetDeliveryTask(c, task.OriginalTaskID); err == nil {
+        orderingKey = original.OrderingKey
+        eventID = original.EventID
+    }
     newTaskID := uuid.New().String()
     err = h.Queries.CreateDeliveryTask(c, database.CreateDeliveryTaskParams{
         ID:             newTaskID,
         SubscriptionID: task.SubscriptionID,
         Payload:        task.Payload,
+        OrderingKey:    orderingKey,
+        EventType:      task.EventType,
+        EventID:        eventID,
     })
     if err != nil {
         c.String(http.StatusInternalServerError, "Failed to requeue: %v", err)
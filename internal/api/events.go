@@ -0,0 +1,278 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/delivery"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// EventHandler accepts events addressed by type rather than by subscription
+// and fans them out to every subscription that wants them.
+type EventHandler struct {
+    DB      *sql.DB
+    Queries *database.Queries
+    // Intake turns away new events during shutdown.
+    Intake *IngestGate
+    // PublishToken is the bearer token that lets a producer publish to
+    // subscriptions with a secret. Without it those subscriptions only take
+    // signed events through /ingest.
+    PublishToken string
+}
+
+// RegisterEventRoutes registers the event endpoints.
+func RegisterEventRoutes(r *gin.Engine, h *EventHandler) {
+    r.POST("/events", h.Intake.Guard(), h.PublishEvent)
+    r.GET("/events", h.ListEvents)
+    r.GET("/events/:id", h.GetEvent)
+    r.GET("/events/:id/deliveries", h.ListEventDeliveries)
+}
+
+// eventSourceHeader lets producers name themselves on the stored event.
+const eventSourceHeader = "X-Event-Source"
+
+// redactedEventHeaders are credentials that are not stored with events.
+// Signatures count: anyone reading the event log could otherwise replay
+// the body and its signature into /ingest.
+var redactedEventHeaders = map[string]bool{
+    "Authorization":         true,
+    "Cookie":                true,
+    "Proxy-Authorization":   true,
+    "X-Hub-Signature":       true,
+    "X-Hub-Signature-256":   true,
+    "Stripe-Signature":      true,
+    "X-Shopify-Hmac-Sha256": true,
+    "X-Slack-Signature":     true,
+    "Webhook-Signature":     true,
+}
+
+// eventSource returns the X-Event-Source header, or def when it is absent.
+func eventSource(c *gin.Context, def string) string {
+    if source := c.GetHeader(eventSourceHeader); source != "" {
+        return source
+    }
+    return def
+}
+
+// eventHeaders encodes the request headers stored with an event.
+func eventHeaders(header http.Header) sql.NullString {
+    kept := make(http.Header, len(header))
+    for name, values := range header {
+        if !redactedEventHeaders[http.CanonicalHeaderKey(name)] {
+            kept[name] = values
+        }
+    }
+    encoded, err := json.Marshal(kept)
+    if err != nil {
+        return sql.NullString{}
+    }
+    return sql.NullString{String: string(encoded), Valid: true}
+}
+
+type publishEventRequest struct {
+    EventType   string          `json:"event_type" binding:"required"`
+    Payload     json.RawMessage `json:"payload" binding:"required"`
+    OrderingKey string          `json:"ordering_key"`
+    Source      string          `json:"source"`
+}
+
+// fanOutTask is one task created for an event.
+type fanOutTask struct {
+    SubscriptionID string `json:"subscription_id"`
+    TaskID         string `json:"task_id"`
+    Reason         string `json:"reason,omitempty"`
+}
+
+// PublishEvent handles POST /events. It creates a delivery task for every
+// subscription whose event types include the event, all sharing one event
+// ID. Subscriptions whose filter rejects the payload get a filtered task
+// instead. Subscriptions with a secret authenticate their producers, so
+// they are only included when the request carries the publish token; the
+// response lists the ones skipped without it. The
+// tasks are created in one transaction: a failed request leaves nothing
+// behind, but retrying one that did succeed creates the tasks again.
+func (h *EventHandler) PublishEvent(c *gin.Context) {
+    trusted, err := h.publishAuthorized(c)
+    if err != nil {
+        c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+        return
+    }
+    var req publishEventRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    orderingKey := req.OrderingKey
+    if orderingKey == "" {
+        orderingKey = c.GetHeader(delivery.OrderingKeyHeader)
+    }
+
+    subs, err := h.Queries.ListSubscriptions(c)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    tx, err := h.DB.BeginTx(c, nil)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    defer tx.Rollback()
+    q := h.Queries.WithTx(tx)
+
+    eventID := uuid.New().String()
+    body := []byte(req.Payload)
+    source := req.Source
+    if source == "" {
+        source = eventSource(c, "events")
+    }
+    err = q.CreateEvent(c, database.CreateEventParams{
+        ID:        eventID,
+        EventType: nullString(req.EventType),
+        Payload:   string(body),
+        Source:    source,
+        Headers:   eventHeaders(c.Request.Header),
+    })
+    if err != nil {
+        log.Printf("Error storing event: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record event"})
+        return
+    }
+    tasks := []fanOutTask{}
+    filtered := []fanOutTask{}
+    skipped := []string{}
+    for _, sub := range subs {
+        if !subscriptionAllowsEvent(sub, req.EventType) {
+            continue
+        }
+        if sub.Secret.Valid && sub.Secret.String != "" && !trusted {
+            skipped = append(skipped, sub.ID)
+            continue
+        }
+        taskID := uuid.New().String()
+        if reason := payloadFilterReason(sub, body); reason != "" {
+            if err := recordFiltered(c, q, sub, taskID, eventID, req.EventType, body, reason); err != nil {
+                log.Printf("Error recording filtered event %s for subscription %s: %v", eventID, sub.ID, err)
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue event"})
+                return
+            }
+            filtered = append(filtered, fanOutTask{SubscriptionID: sub.ID, TaskID: taskID, Reason: reason})
+            continue
+        }
+        err := q.CreateDeliveryTask(c, database.CreateDeliveryTaskParams{
+            ID:             taskID,
+            SubscriptionID: sub.ID,
+            Payload:        string(body),
+            OrderingKey:    delivery.OrderingKey(sub, orderingKey),
+            EventType:      nullString(req.EventType),
+            EventID:        nullString(eventID),
+        })
+        if err != nil {
+            log.Printf("Error creating delivery task for event %s, subscription %s: %v", eventID, sub.ID, err)
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue event"})
+            return
+        }
+        tasks = append(tasks, fanOutTask{SubscriptionID: sub.ID, TaskID: taskID})
+    }
+    if err := tx.Commit(); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    c.JSON(http.StatusAccepted, gin.H{
+        "event_id": eventID,
+        "tasks":    tasks,
+        "filtered": filtered,
+        "skipped":  skipped,
+    })
+}
+
+// publishAuthorized reports whether the request carries the publish token.
+// A request without credentials is allowed but untrusted; a wrong token is
+// an error.
+func (h *EventHandler) publishAuthorized(c *gin.Context) (bool, error) {
+    header := c.GetHeader("Authorization")
+    if header == "" {
+        return false, nil
+    }
+    token, ok := strings.CutPrefix(header, "Bearer ")
+    if !ok || h.PublishToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.PublishToken)) != 1 {
+        return false, errors.New("invalid publish token")
+    }
+    return true, nil
+}
+
+// ListEvents handles GET /events?event_type=&limit=&offset=
+func (h *EventHandler) ListEvents(c *gin.Context) {
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+    if err != nil || limit <= 0 || limit > 500 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+        return
+    }
+    offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+    if err != nil || offset < 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "offset must not be negative"})
+        return
+    }
+    events, err := h.Queries.ListEvents(c, database.ListEventsParams{
+        EventType: c.Query("event_type"),
+        Limit:     int64(limit),
+        Offset:    int64(offset),
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch events"})
+        return
+    }
+    if events == nil {
+        events = []database.Event{}
+    }
+    c.JSON(http.StatusOK, events)
+}
+
+// GetEvent handles GET /events/:id
+func (h *EventHandler) GetEvent(c *gin.Context) {
+    event, err := h.Queries.GetEvent(c, c.Param("id"))
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+        return
+    }
+    c.JSON(http.StatusOK, event)
+}
+
+// ListEventDeliveries handles GET /events/:id/deliveries. It returns every
+// task the event produced, each with its delivery attempts.
+func (h *EventHandler) ListEventDeliveries(c *gin.Context) {
+    id := c.Param("id")
+    if _, err := h.Queries.GetEvent(c, id); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+        return
+    }
+    tasks, err := h.Queries.ListDeliveryTasksForEvent(c, sql.NullString{String: id, Valid: true})
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch deliveries"})
+        return
+    }
+    deliveries := make([]gin.H, 0, len(tasks))
+    for _, task := range tasks {
+        logs, err := h.Queries.ListDeliveryLogsForTask(c, task.ID)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fetch logs"})
+            return
+        }
+        deliveries = append(deliveries, gin.H{
+            "task": task,
+            "logs": logs,
+        })
+    }
+    c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/gin-gonic/gin"
+	_ "github.com/tursodatabase/go-libsql"
+)
+
+// openTestDB returns a fresh SQLite database with every migration applied.
+func openTestDB(t *testing.T) *sql.DB {
+    t.Helper()
+    db, err := sql.Open("libsql", "file:"+filepath.Join(t.TempDir(), "test.db"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { db.Close() })
+    files, err := filepath.Glob("../sql/schema/*.sql")
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, file := range files {
+        b, err := os.ReadFile(file)
+        if err != nil {
+            t.Fatal(err)
+        }
+        up, _, _ := strings.Cut(string(b), "-- +goose down")
+        var lines []string
+        for _, line := range strings.Split(up, "\n") {
+            if i := strings.Index(line, "--"); i >= 0 {
+                line = line[:i]
+            }
+            lines = append(lines, line)
+        }
+        for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+            if strings.TrimSpace(stmt) == "" {
+                continue
+            }
+            if _, err := db.Exec(stmt); err != nil {
+                t.Fatalf("%s: %v", file, err)
+            }
+        }
+    }
+    return db
+}
+
+func TestPublishEventReportsSkippedSubscriptions(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    db := openTestDB(t)
+    _, err := db.Exec(`INSERT INTO subscriptions (id, target_url, secret) VALUES ('open', 'https://a.example', NULL), ('signed', 'https://b.example', 'k')`)
+    if err != nil {
+        t.Fatal(err)
+    }
+    r := gin.New()
+    RegisterEventRoutes(r, &EventHandler{DB: db, Queries: database.New(db), PublishToken: "token"})
+
+    publish := func(auth string) (int, map[string]json.RawMessage) {
+        req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"event_type":"order.created","payload":{}}`))
+        if auth != "" {
+            req.Header.Set("Authorization", auth)
+        }
+        rec := httptest.NewRecorder()
+        r.ServeHTTP(rec, req)
+        var body map[string]json.RawMessage
+        json.Unmarshal(rec.Body.Bytes(), &body)
+        return rec.Code, body
+    }
+
+    code, body := publish("")
+    if code != http.StatusAccepted {
+        t.Fatalf("status = %d, want 202", code)
+    }
+    var tasks []fanOutTask
+    var skipped []string
+    json.Unmarshal(body["tasks"], &tasks)
+    json.Unmarshal(body["skipped"], &skipped)
+    if len(tasks) != 1 || tasks[0].SubscriptionID != "open" {
+        t.Errorf("tasks = %+v, want one for subscription open", tasks)
+    }
+    if len(skipped) != 1 || skipped[0] != "signed" {
+        t.Errorf("skipped = %v, want [signed]", skipped)
+    }
+
+    code, body = publish("Bearer token")
+    json.Unmarshal(body["tasks"], &tasks)
+    json.Unmarshal(body["skipped"], &skipped)
+    if code != http.StatusAccepted || len(tasks) != 2 || len(skipped) != 0 {
+        t.Errorf("with the token: status %d, tasks %+v, skipped %v; want 202, 2 tasks, none skipped", code, tasks, skipped)
+    }
+
+    if code, _ := publish("Bearer wrong"); code != http.StatusUnauthorized {
+        t.Errorf("wrong token: status = %d, want 401", code)
+    }
+}
+
+func TestEventHeadersDropsCredentialsAndSignatures(t *testing.T) {
+    header := http.Header{}
+    header.Set("Content-Type", "application/json")
+    header.Set("Authorization", "Bearer token")
+    header.Set("X-Hub-Signature-256", "sha256=abc")
+    header.Set("Stripe-Signature", "t=1,v1=abc")
+    header.Set("X-Shopify-Hmac-Sha256", "abc")
+    header.Set("X-Slack-Signature", "v0=abc")
+    header.Set("webhook-signature", "v1,abc")
+
+    stored := eventHeaders(header)
+    var kept http.Header
+    if err := json.Unmarshal([]byte(stored.String), &kept); err != nil {
+        t.Fatal(err)
+    }
+    if len(kept) != 1 || kept.Get("Content-Type") != "application/json" {
+        t.Errorf("stored headers = %v, want only Content-Type", kept)
+    }
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/delivery"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+    // timestampHeader carries the Unix time a replay-protected request was
+    // signed at.
+    timestampHeader = "X-Webhook-Timestamp"
+    // defaultTimestampTolerance is used when WebhookHandler has none set.
+    defaultTimestampTolerance = 5 * time.Minute
+)
+
+var (
+    errInvalidSignature = errors.New("invalid signature")
+    errSignatureReused  = errors.New("signature was already used")
+)
+
+// verifyIngestRequest checks the signature of a request ingested for sub,
+// which has a secret, with the subscription's verification scheme. A
+// signed timestamp must be within the tolerance window. With replay
+// protection each signature is also accepted only once: the nonce claimed
+// for it is returned so it can be released if the request fails.
+func (h *WebhookHandler) verifyIngestRequest(c *gin.Context, sub database.Subscription, body []byte) (string, error) {
+    verifier, err := verifierFor(sub)
+    if err != nil {
+        return "", err
+    }
+    verified, err := verifier.Verify(c.Request.Header, body, delivery.ActiveSecrets(sub, time.Now()))
+    if err != nil {
+        return "", err
+    }
+    if verified.SignedAt.IsZero() {
+        return "", nil
+    }
+
+    tolerance := h.TimestampTolerance
+    if tolerance <= 0 {
+        tolerance = defaultTimestampTolerance
+    }
+    if age := time.Since(verified.SignedAt); age > tolerance || age < -tolerance {
+        return "", errors.New("request timestamp is outside the tolerance window")
+    }
+
+    if !sub.ReplayProtection || h.Nonces == nil {
+        return "", nil
+    }
+    // A signature can only be replayed while its timestamp is in the
+    // window, so it need not be remembered longer than that.
+    nonce := "ingest:" + sub.ID + ":" + verified.Signature
+    fresh, err := h.Nonces.Claim(c, nonce, 2*tolerance)
+    if err != nil {
+        log.Printf("nonce cache unavailable for subscription %s, relying on the timestamp window: %v", sub.ID, err)
+        return "", nil
+    }
+    if !fresh {
+        return "", errSignatureReused
+    }
+    return nonce, nil
+}
+
+// releaseNonce lets the signature claimed by a failed request be used
+// again, so the producer's retry is not mistaken for a replay.
+func (h *WebhookHandler) releaseNonce(c *gin.Context, nonce string) {
+    if nonce != "" {
+        h.Nonces.Release(c, nonce)
+    }
+}
+
+// signedPayload is what a replay-protected request's signature covers.
+func signedPayload(timestamp string, body []byte) []byte {
+    return append([]byte(timestamp+"."), body...)
+}
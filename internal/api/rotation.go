@@ -0,0 +1,130 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/delivery"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// rotateSecretRequest is the optional body of a secret rotation. Without a
+// secret one is generated; without a grace period the service default is
+// used.
+type rotateSecretRequest struct {
+    Secret             string `json:"secret"`
+    GracePeriodSeconds *int64 `json:"grace_period_seconds"`
+}
+
+// RotateSubscriptionSecret handles POST /subscriptions/:id/rotate-secret.
+// The new secret takes over immediately; the old one keeps verifying
+// ingested requests and is used as a second outbound signature until the
+// grace period ends.
+func (h *SubscriptionHandler) RotateSubscriptionSecret(c *gin.Context) {
+    id := c.Param("id")
+    var req rotateSecretRequest
+    if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    grace := h.SecretGracePeriod
+    if req.GracePeriodSeconds != nil {
+        if *req.GracePeriodSeconds < 0 {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "grace_period_seconds must not be negative"})
+            return
+        }
+        grace = time.Duration(*req.GracePeriodSeconds) * time.Second
+    }
+    sub, err := h.Queries.GetSubscription(c, id)
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+        return
+    }
+
+    secret, generated := req.Secret, false
+    if secret == "" {
+        if secret, err = delivery.NewSecret(sub.SigningProfile); err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        generated = true
+    }
+    if err := delivery.ValidateSigningProfile(sub.SigningProfile, secret); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if secret == sub.Secret.String {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "new secret must differ from the current one"})
+        return
+    }
+    var expiresAt sql.NullTime
+    if sub.Secret.Valid && sub.Secret.String != "" {
+        expiresAt = sql.NullTime{Time: time.Now().Add(grace).UTC(), Valid: true}
+    }
+
+    tx, err := h.DB.BeginTx(c, nil)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    defer tx.Rollback()
+    q := h.Queries.WithTx(tx)
+
+    err = q.RotateSubscriptionSecret(c, database.RotateSubscriptionSecretParams{
+        PreviousSecretExpiresAt: expiresAt,
+        Secret:                  nullString(secret),
+        ID:                      id,
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    rotationID := uuid.New().String()
+    err = q.CreateSecretRotation(c, database.CreateSecretRotationParams{
+        ID:                      rotationID,
+        SubscriptionID:          id,
+        PreviousSecretExpiresAt: expiresAt,
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if err := tx.Commit(); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if h.Cache != nil {
+        h.Cache.Del(id)
+    }
+
+    resp := gin.H{"rotation_id": rotationID, "previous_secret_expires_at": nil}
+    if expiresAt.Valid {
+        resp["previous_secret_expires_at"] = expiresAt.Time
+    }
+    if generated {
+        // Returned once so the caller can hand it to producers and consumers.
+        resp["secret"] = secret
+    }
+    c.JSON(http.StatusOK, resp)
+}
+
+// ListSecretRotations handles GET /subscriptions/:id/secret-rotations. The
+// history records when each rotation happened, never the secrets.
+func (h *SubscriptionHandler) ListSecretRotations(c *gin.Context) {
+    id := c.Param("id")
+    if _, err := h.Queries.GetSubscription(c, id); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+        return
+    }
+    rotations, err := h.Queries.ListSecretRotations(c, id)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    c.JSON(http.StatusOK, rotations)
+}
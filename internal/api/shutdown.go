@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IngestGate stops the service from taking new events while it shuts down,
+// so the worker only has to drain deliveries that are already running.
+type IngestGate struct {
+    closed atomic.Bool
+}
+
+// Close makes Guard turn away new events from now on.
+func (g *IngestGate) Close() {
+    g.closed.Store(true)
+}
+
+// Closed reports whether the gate was closed. A nil gate is always open.
+func (g *IngestGate) Closed() bool {
+    return g != nil && g.closed.Load()
+}
+
+// Guard answers 503 with Retry-After once the gate is closed, so producers
+// resend the event to another instance or after the restart.
+func (g *IngestGate) Guard() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if g.Closed() {
+            c.Header("Retry-After", "5")
+            c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "service is shutting down"})
+            return
+        }
+        c.Next()
+    }
+}
@@ -2,17 +2,32 @@ package api
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/cache"
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/delivery"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/filter"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/secrets"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/transform"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type SubscriptionHandler struct {
+    DB      *sql.DB
     Queries *database.Queries
     Cache   *cache.RedisSubscriptionCache
+    Secrets *secrets.Box
+    Targets *delivery.TargetPolicy
+    // SecretGracePeriod is how long a rotated-out secret stays valid when
+    // the rotation request does not say.
+    SecretGracePeriod time.Duration
 }
 
 func RegisterSubscriptionRoutes(r *gin.Engine, h *SubscriptionHandler) {
@@ -21,25 +36,269 @@ func RegisterSubscriptionRoutes(r *gin.Engine, h *SubscriptionHandler) {
     r.GET("/subscriptions/:id", h.GetSubscription)
     r.PUT("/subscriptions/:id", h.UpdateSubscription)
     r.DELETE("/subscriptions/:id", h.DeleteSubscription)
+    r.PUT("/subscriptions/:id/tls", h.PutSubscriptionTLS)
+    r.DELETE("/subscriptions/:id/tls", h.DeleteSubscriptionTLS)
+    r.PUT("/subscriptions/:id/auth", h.PutSubscriptionAuth)
+    r.DELETE("/subscriptions/:id/auth", h.DeleteSubscriptionAuth)
+    r.POST("/subscriptions/:id/rotate-secret", h.RotateSubscriptionSecret)
+    r.GET("/subscriptions/:id/secret-rotations", h.ListSecretRotations)
+    r.POST("/transform/preview", h.PreviewTransform)
 }
+
+// subscriptionRequest is the subscription body accepted both as JSON by the
+// API and as form fields by the UI. Zero-valued retry fields mean "use the
+// service default".
+type subscriptionRequest struct {
+    TargetURL             string  `json:"target_url" form:"target_url" binding:"required"`
+    Secret                string  `json:"secret" form:"secret"`
+    SigningProfile        string  `json:"signing_profile" form:"signing_profile"`
+    EventTypes            string  `json:"event_types" form:"event_types"` // comma-separated
+    Filter                string  `json:"filter" form:"filter"`
+    MaxAttempts           int64   `json:"max_attempts" form:"max_attempts"`
+    RetryBaseDelaySeconds int64   `json:"retry_base_delay_seconds" form:"retry_base_delay_seconds"`
+    RetryMaxDelaySeconds  int64   `json:"retry_max_delay_seconds" form:"retry_max_delay_seconds"`
+    RetryMultiplier       float64 `json:"retry_multiplier" form:"retry_multiplier"`
+    RetryJitter           float64 `json:"retry_jitter" form:"retry_jitter"`
+    RetrySchedule         string  `json:"retry_schedule" form:"retry_schedule"` // comma-separated durations
+    RateLimitPerSecond    float64 `json:"rate_limit_per_second" form:"rate_limit_per_second"`
+    RateLimitBurst        int64   `json:"rate_limit_burst" form:"rate_limit_burst"`
+    Ordered               bool    `json:"ordered" form:"ordered"`
+    ReplayProtection      bool    `json:"replay_protection" form:"replay_protection"`
+    VerificationScheme    string  `json:"verification_scheme" form:"verification_scheme"`
+    BatchMaxSize          int64   `json:"batch_max_size" form:"batch_max_size"`
+    BatchMaxWaitSeconds   int64   `json:"batch_max_wait_seconds" form:"batch_max_wait_seconds"`
+    // Headers are extra request headers; the UI sends them as HeadersText,
+    // one "Name: value" per line.
+    Headers     map[string]string `json:"headers" form:"-"`
+    HeadersText string            `json:"-" form:"headers"`
+    // Transform is the payload transformation spec; the UI sends it as
+    // TransformText.
+    Transform     json.RawMessage `json:"transform" form:"-"`
+    TransformText string          `json:"-" form:"transform"`
+}
+
+func (r subscriptionRequest) validate(targets *delivery.TargetPolicy) error {
+    if err := targets.ValidateURL(r.TargetURL); err != nil {
+        return err
+    }
+    if err := delivery.ValidateSigningProfile(r.SigningProfile, r.Secret); err != nil {
+        return err
+    }
+    verifier, ok := SignatureVerifiers[r.verificationScheme()]
+    if !ok {
+        return fmt.Errorf("unknown verification_scheme %q", r.VerificationScheme)
+    }
+    if r.ReplayProtection {
+        if r.Secret == "" {
+            return errors.New("replay_protection requires a secret")
+        }
+        if r.verificationScheme() != VerificationHMACSHA256 && !verifier.SignsTimestamp() {
+            return fmt.Errorf("replay_protection is not supported by verification_scheme %s, which does not sign a timestamp", r.VerificationScheme)
+        }
+    }
+    if r.MaxAttempts < 0 || r.RetryBaseDelaySeconds < 0 || r.RetryMaxDelaySeconds < 0 {
+        return errors.New("retry attempts and delays must not be negative")
+    }
+    if r.RetryMultiplier != 0 && r.RetryMultiplier < 1 {
+        return errors.New("retry_multiplier must be at least 1")
+    }
+    if r.RetryJitter < 0 || r.RetryJitter > 1 {
+        return errors.New("retry_jitter must be between 0 and 1")
+    }
+    if r.RateLimitPerSecond < 0 || r.RateLimitBurst < 0 {
+        return errors.New("rate_limit_per_second and rate_limit_burst must not be negative")
+    }
+    if r.BatchMaxSize < 0 || r.BatchMaxWaitSeconds < 0 {
+        return errors.New("batch_max_size and batch_max_wait_seconds must not be negative")
+    }
+    if r.BatchMaxSize > 1 && r.signingProfile() == delivery.SigningProfileStandard {
+        // A batch is regrouped on every retry, so it has no webhook-id that
+        // stays the same across retries as the spec requires.
+        return errors.New("batch_max_size greater than 1 is not supported with the standard-webhooks signing profile")
+    }
+    headers, err := r.headers()
+    if err != nil {
+        return err
+    }
+    if err := delivery.ValidateHeaders(headers); err != nil {
+        return err
+    }
+    if r.RetrySchedule != "" {
+        if _, err := delivery.ParseRetrySchedule(r.RetrySchedule); err != nil {
+            return err
+        }
+    }
+    if strings.TrimSpace(r.Filter) != "" {
+        if _, err := filter.Parse(r.Filter); err != nil {
+            return err
+        }
+    }
+    if spec := r.transformSpec(); spec != "" {
+        if err := transform.Validate(spec); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (r subscriptionRequest) createParams(id string) database.CreateSubscriptionParams {
+    return database.CreateSubscriptionParams{
+        ID:                    id,
+        TargetUrl:             r.TargetURL,
+        Secret:                nullString(r.Secret),
+        EventTypes:            nullString(r.EventTypes),
+        Filter:                nullString(strings.TrimSpace(r.Filter)),
+        MaxAttempts:           nullInt64(r.MaxAttempts),
+        RetryBaseDelaySeconds: nullInt64(r.RetryBaseDelaySeconds),
+        RetryMaxDelaySeconds:  nullInt64(r.RetryMaxDelaySeconds),
+        RetryMultiplier:       nullFloat64(r.RetryMultiplier),
+        RetryJitter:           nullFloat64(r.RetryJitter),
+        RetrySchedule:         nullString(r.RetrySchedule),
+        RateLimitPerSecond:    nullFloat64(r.RateLimitPerSecond),
+        RateLimitBurst:        nullInt64(r.RateLimitBurst),
+        Ordered:               r.Ordered,
+        BatchMaxSize:          nullInt64(r.BatchMaxSize),
+        BatchMaxWaitSeconds:   nullInt64(r.BatchMaxWaitSeconds),
+        Headers:               r.headersParam(),
+        Transform:             nullString(r.transformSpec()),
+        SigningProfile:        r.signingProfile(),
+        ReplayProtection:      r.ReplayProtection,
+        VerificationScheme:    r.verificationScheme(),
+    }
+}
+
+func (r subscriptionRequest) updateParams(id string) database.UpdateSubscriptionParams {
+    return database.UpdateSubscriptionParams{
+        TargetUrl:             r.TargetURL,
+        Secret:                nullString(r.Secret),
+        EventTypes:            nullString(r.EventTypes),
+        Filter:                nullString(strings.TrimSpace(r.Filter)),
+        MaxAttempts:           nullInt64(r.MaxAttempts),
+        RetryBaseDelaySeconds: nullInt64(r.RetryBaseDelaySeconds),
+        RetryMaxDelaySeconds:  nullInt64(r.RetryMaxDelaySeconds),
+        RetryMultiplier:       nullFloat64(r.RetryMultiplier),
+        RetryJitter:           nullFloat64(r.RetryJitter),
+        RetrySchedule:         nullString(r.RetrySchedule),
+        RateLimitPerSecond:    nullFloat64(r.RateLimitPerSecond),
+        RateLimitBurst:        nullInt64(r.RateLimitBurst),
+        Ordered:               r.Ordered,
+        BatchMaxSize:          nullInt64(r.BatchMaxSize),
+        BatchMaxWaitSeconds:   nullInt64(r.BatchMaxWaitSeconds),
+        Headers:               r.headersParam(),
+        Transform:             nullString(r.transformSpec()),
+        SigningProfile:        r.signingProfile(),
+        ReplayProtection:      r.ReplayProtection,
+        VerificationScheme:    r.verificationScheme(),
+        ID:                    id,
+    }
+}
+
+// signingProfile returns the requested signing profile or the default.
+func (r subscriptionRequest) signingProfile() string {
+    if r.SigningProfile == "" {
+        return delivery.SigningProfileHMACSHA256
+    }
+    return r.SigningProfile
+}
+
+// verificationScheme returns the requested inbound verification scheme or
+// the default.
+func (r subscriptionRequest) verificationScheme() string {
+    if r.VerificationScheme == "" {
+        return VerificationHMACSHA256
+    }
+    return r.VerificationScheme
+}
+
+// generateSecret fills in a whsec_ secret when a new subscription chooses
+// the Standard Webhooks profile without one.
+func (r *subscriptionRequest) generateSecret() error {
+    if r.SigningProfile != delivery.SigningProfileStandard || r.Secret != "" {
+        return nil
+    }
+    secret, err := delivery.NewStandardSecret()
+    if err != nil {
+        return err
+    }
+    r.Secret = secret
+    return nil
+}
+
+// headers returns the custom headers from whichever of Headers and
+// HeadersText was sent.
+func (r subscriptionRequest) headers() (map[string]string, error) {
+    if r.HeadersText == "" {
+        return r.Headers, nil
+    }
+    headers := map[string]string{}
+    for _, line := range strings.Split(r.HeadersText, "\n") {
+        line = strings.TrimSpace(line)
+        if line == "" {
+            continue
+        }
+        name, value, ok := strings.Cut(line, ":")
+        if !ok {
+            return nil, fmt.Errorf("invalid header line %q, expected \"Name: value\"", line)
+        }
+        headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+    }
+    return headers, nil
+}
+
+// headersParam encodes the custom headers for storage, NULL when there are none.
+func (r subscriptionRequest) headersParam() sql.NullString {
+    headers, err := r.headers()
+    if err != nil || len(headers) == 0 {
+        return sql.NullString{}
+    }
+    encoded, err := json.Marshal(headers)
+    if err != nil {
+        return sql.NullString{}
+    }
+    return sql.NullString{String: string(encoded), Valid: true}
+}
+
+// transformSpec returns the transformation spec from whichever of Transform
+// and TransformText was sent, or "" when there is none.
+func (r subscriptionRequest) transformSpec() string {
+    if text := strings.TrimSpace(r.TransformText); text != "" {
+        return text
+    }
+    if len(r.Transform) == 0 || string(r.Transform) == "null" {
+        return ""
+    }
+    return string(r.Transform)
+}
+
+func nullString(s string) sql.NullString {
+    return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt64(n int64) sql.NullInt64 {
+    return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
+func nullFloat64(f float64) sql.NullFloat64 {
+    return sql.NullFloat64{Float64: f, Valid: f != 0}
+}
+
 // CreateSubscription handles POST /subscriptions
 func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
-    var req struct {
-        TargetUrl  string `json:"target_url" binding:"required"`
-        Secret     string `json:"secret"`
-        EventTypes string `json:"event_types"` // comma-separated
-    }
+    var req subscriptionRequest
     if err := c.ShouldBindJSON(&req); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    if err := req.generateSecret(); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if err := req.validate(h.Targets); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
     id := uuid.New().String()
-    err := h.Queries.CreateSubscription(c, database.CreateSubscriptionParams{
-        ID:         id,
-        TargetUrl:  req.TargetUrl,
-        Secret:     sql.NullString{String: req.Secret, Valid: req.Secret != ""},
-        EventTypes: sql.NullString{String: req.EventTypes, Valid: req.EventTypes != ""},
-    })
+    err := h.Queries.CreateSubscription(c, req.createParams(id))
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
@@ -54,6 +313,9 @@ func (h *SubscriptionHandler) ListSubscriptions(c *gin.Context) {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
+    for i := range subs {
+        subs[i] = redactSubscription(subs[i])
+    }
     c.JSON(http.StatusOK, subs)
 }
 
@@ -65,34 +327,22 @@ func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
         c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
         return
     }
-    c.JSON(http.StatusOK, sub)
+    c.JSON(http.StatusOK, redactSubscription(sub))
 }
 
 // UpdateSubscription handles PUT /subscriptions/:id
 func (h *SubscriptionHandler) UpdateSubscription(c *gin.Context) {
     id := c.Param("id")
-    var req struct {
-        TargetURL string `json:"target_url" binding:"required"`
-        Secret    string `json:"secret"`
-        EventTypes string `json:"event_types"` 
-    }
+    var req subscriptionRequest
     if err := c.ShouldBindJSON(&req); err != nil {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
-    arg := database.UpdateSubscriptionParams{
-        TargetUrl: req.TargetURL,
-        EventTypes: sql.NullString{
-            String: req.EventTypes,
-            Valid:  req.EventTypes != "",
-        },
-		Secret: sql.NullString{
-			String: req.Secret,
-			Valid:  req.Secret != "",
-		},
-        ID:        id,
-    }
-    if err := h.Queries.UpdateSubscription(c, arg); err != nil {
+    if err := req.validate(h.Targets); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if err := h.Queries.UpdateSubscription(c, req.updateParams(id)); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
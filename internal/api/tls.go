@@ -0,0 +1,115 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"net/http"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/delivery"
+	"github.com/gin-gonic/gin"
+)
+
+// subscriptionTLSRequest is the body of PUT /subscriptions/:id/tls. All
+// fields are PEM encoded.
+type subscriptionTLSRequest struct {
+    ClientCert       string `json:"client_cert"`
+    ClientKey        string `json:"client_key"`
+    CABundle         string `json:"ca_bundle"`
+    PinnedServerCert string `json:"pinned_server_cert"`
+}
+
+// PutSubscriptionTLS handles PUT /subscriptions/:id/tls
+func (h *SubscriptionHandler) PutSubscriptionTLS(c *gin.Context) {
+    id := c.Param("id")
+    var req subscriptionTLSRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if req.ClientCert == "" && req.CABundle == "" && req.PinnedServerCert == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "client_cert, ca_bundle or pinned_server_cert is required"})
+        return
+    }
+    if (req.ClientCert == "") != (req.ClientKey == "") {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "client_cert and client_key must be provided together"})
+        return
+    }
+    pin, err := certFingerprint(req.PinnedServerCert)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if _, err := delivery.NewTLSConfig(req.ClientCert, req.ClientKey, req.CABundle, pin); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    if _, err := h.Queries.GetSubscription(c, id); err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"error": "subscription not found"})
+        return
+    }
+
+    var encryptedKey string
+    if req.ClientKey != "" {
+        encryptedKey, err = h.Secrets.Encrypt(req.ClientKey)
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot store client key: " + err.Error()})
+            return
+        }
+    }
+    err = h.Queries.UpdateSubscriptionTLS(c, database.UpdateSubscriptionTLSParams{
+        TlsClientCert:         nullString(req.ClientCert),
+        TlsClientKeyEncrypted: nullString(encryptedKey),
+        TlsCaBundle:           nullString(req.CABundle),
+        TlsPinnedCertSha256:   nullString(pin),
+        ID:                    id,
+    })
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if h.Cache != nil {
+        h.Cache.Del(id)
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// DeleteSubscriptionTLS handles DELETE /subscriptions/:id/tls
+func (h *SubscriptionHandler) DeleteSubscriptionTLS(c *gin.Context) {
+    id := c.Param("id")
+    err := h.Queries.UpdateSubscriptionTLS(c, database.UpdateSubscriptionTLSParams{ID: id})
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if h.Cache != nil {
+        h.Cache.Del(id)
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// certFingerprint returns the hex SHA-256 of the first certificate in a PEM
+// block, or "" when certPEM is empty.
+func certFingerprint(certPEM string) (string, error) {
+    if certPEM == "" {
+        return "", nil
+    }
+    block, _ := pem.Decode([]byte(certPEM))
+    if block == nil || block.Type != "CERTIFICATE" {
+        return "", errors.New("pinned_server_cert must be a PEM certificate")
+    }
+    sum := sha256.Sum256(block.Bytes)
+    return hex.EncodeToString(sum[:]), nil
+}
+
+// redactSubscription strips stored key material, credentials and the
+// rotated-out secret before a subscription is returned by the API.
+func redactSubscription(sub database.Subscription) database.Subscription {
+    sub.TlsClientKeyEncrypted = sql.NullString{}
+    sub.AuthConfigEncrypted = sql.NullString{}
+    sub.PreviousSecret = sql.NullString{}
+    return sub
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/transform"
+	"github.com/gin-gonic/gin"
+)
+
+// transformPreviewRequest is a transformation spec and a sample payload to
+// try it on.
+type transformPreviewRequest struct {
+    Transform json.RawMessage `json:"transform" binding:"required"`
+    Payload   json.RawMessage `json:"payload" binding:"required"`
+}
+
+// PreviewTransform handles POST /transform/preview
+func (h *SubscriptionHandler) PreviewTransform(c *gin.Context) {
+    var req transformPreviewRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    spec := string(req.Transform)
+    if err := transform.Validate(spec); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    output, err := transform.Apply(spec, req.Payload)
+    if err != nil {
+        c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "transformation failed: " + err.Error()})
+        return
+    }
+    c.JSON(http.StatusOK, gin.H{"output": json.RawMessage(output)})
+}
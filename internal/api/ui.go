@@ -2,14 +2,16 @@ package api
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
+	"encoding/json"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/cache"
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/delivery"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -17,6 +19,8 @@ import (
 type UIHandler struct {
     Queries *database.Queries
     Cache *cache.RedisSubscriptionCache
+    Breakers *delivery.CircuitBreakers
+    Targets *delivery.TargetPolicy
 }
 
 func RegisterUIRoutes(r *gin.Engine, h *UIHandler) {
@@ -28,6 +32,7 @@ func RegisterUIRoutes(r *gin.Engine, h *UIHandler) {
     r.GET("/ui/subscriptions/:id/test", h.TestWebhookForm) 
     r.GET("/ui/subscriptions/:id/analytics", h.SubscriptionAnalyticsPage)
     r.GET("/api/subscriptions/:id/logs", h.GetLogsJSON)
+    r.GET("/ui/deliveries/:id", h.DeliveryDetailPage)
     r.GET("/ui/subscriptions/:id/edit", h.EditSubscriptionForm)
     r.POST("/ui/subscriptions/:id/edit", h.UpdateSubscriptionForm)
     r.POST("/ui/subscriptions/:id/delete", h.DeleteSubscription)
@@ -42,8 +47,13 @@ func (h *UIHandler) ListSubscriptionsPage(c *gin.Context) {
         c.String(http.StatusInternalServerError, "Error: %v", err)
         return
     }
+    breakerStates := make(map[string]string, len(subs))
+    for _, sub := range subs {
+        breakerStates[sub.ID] = h.Breakers.State(delivery.TargetHost(sub.TargetUrl))
+    }
     c.HTML(http.StatusOK, "subscriptions.html", gin.H{
         "Subscriptions": subs,
+        "BreakerStates": breakerStates,
     })
 }
 // NewSubscriptionForm handles GET /ui/subscriptions/new
@@ -58,20 +68,47 @@ func (h *UIHandler) EditSubscriptionForm(c *gin.Context) {
         c.String(404, "Subscription not found")
         return
     }
-    c.HTML(200, "edit_subscription.html", gin.H{"Subscription": sub})
+    c.HTML(200, "edit_subscription.html", gin.H{
+        "Subscription": sub,
+        "HeadersText":  headerLines(sub.Headers),
+    })
+}
+
+// headerLines formats stored custom headers as "Name: value" lines, sorted
+// by name, for the edit form.
+func headerLines(raw sql.NullString) string {
+    var headers map[string]string
+    if !raw.Valid || json.Unmarshal([]byte(raw.String), &headers) != nil {
+        return ""
+    }
+    names := make([]string, 0, len(headers))
+    for name := range headers {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    var b strings.Builder
+    for _, name := range names {
+        b.WriteString(name + ": " + headers[name] + "\n")
+    }
+    return b.String()
 }
 // CreateSubscriptionForm handles POST /ui/subscriptions/new
 func (h *UIHandler) CreateSubscriptionForm(c *gin.Context) {
-    targetURL := c.PostForm("target_url")
-    secret := c.PostForm("secret")
-    eventTypes := c.PostForm("event_types")
+    var req subscriptionRequest
+    if err := c.ShouldBind(&req); err != nil {
+        c.String(http.StatusBadRequest, "Invalid form: %v", err)
+        return
+    }
+    if err := req.generateSecret(); err != nil {
+        c.String(http.StatusInternalServerError, "Error: %v", err)
+        return
+    }
+    if err := req.validate(h.Targets); err != nil {
+        c.String(http.StatusBadRequest, "Invalid form: %v", err)
+        return
+    }
     id := uuid.New().String()
-    err := h.Queries.CreateSubscription(c, database.CreateSubscriptionParams{
-        ID:         id,
-        TargetUrl:  targetURL,
-        Secret:     sql.NullString{String: secret, Valid: secret != ""},
-        EventTypes: sql.NullString{String: eventTypes, Valid: eventTypes != ""},
-    })
+    err := h.Queries.CreateSubscription(c, req.createParams(id))
     if err != nil {
         c.String(http.StatusInternalServerError, "Error: %v", err)
         return
@@ -81,15 +118,16 @@ func (h *UIHandler) CreateSubscriptionForm(c *gin.Context) {
 // UpdateSubscriptionForm handles POST /ui/subscriptions/:id/edit
 func (h *UIHandler) UpdateSubscriptionForm(c *gin.Context) {
     id := c.Param("id")
-    targetURL := c.PostForm("target_url")
-    secret := c.PostForm("secret")
-    eventTypes := c.PostForm("event_types")
-    err := h.Queries.UpdateSubscription(c, database.UpdateSubscriptionParams{
-        TargetUrl:  targetURL,
-        Secret:     sql.NullString{String: secret, Valid: secret != ""},
-        EventTypes: sql.NullString{String: eventTypes, Valid: eventTypes != ""},
-        ID:         id,
-    })
+    var req subscriptionRequest
+    if err := c.ShouldBind(&req); err != nil {
+        c.String(http.StatusBadRequest, "Invalid form: %v", err)
+        return
+    }
+    if err := req.validate(h.Targets); err != nil {
+        c.String(http.StatusBadRequest, "Invalid form: %v", err)
+        return
+    }
+    err := h.Queries.UpdateSubscription(c, req.updateParams(id))
     if err != nil {
         c.String(500, "Update failed: %v", err)
         return
@@ -144,10 +182,9 @@ func (h *UIHandler) SendTestWebhook(c *gin.Context) {
 
     sub, err := h.Queries.GetSubscription(c, id)
     if err == nil && sub.Secret.Valid && sub.Secret.String != "" {
-        mac := hmac.New(sha256.New, []byte(sub.Secret.String))
-        mac.Write([]byte(payload))
-        signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
-        req.Header.Set("X-Hub-Signature-256", signature)
+        if verifier, err := verifierFor(sub); err == nil {
+            verifier.Sign(req.Header, []byte(payload), sub.Secret.String, time.Now())
+        }
     }
 
     client := &http.Client{}
@@ -171,6 +208,24 @@ func (h *UIHandler) SubscriptionAnalyticsPage(c *gin.Context) {
 
     c.JSON(http.StatusOK, logs)
 }
+// DeliveryDetailPage handles GET /ui/deliveries/:id
+func (h *UIHandler) DeliveryDetailPage(c *gin.Context) {
+    id := c.Param("id")
+    task, err := h.Queries.GetDeliveryTask(c, id)
+    if err != nil {
+        c.String(http.StatusNotFound, "Delivery task not found")
+        return
+    }
+    logs, err := h.Queries.ListDeliveryLogsForTask(c, id)
+    if err != nil {
+        c.String(http.StatusInternalServerError, "Error: %v", err)
+        return
+    }
+    c.HTML(http.StatusOK, "delivery.html", gin.H{
+        "Task": task,
+        "Logs": logs,
+    })
+}
 // GetLogsJSON handles GET /api/subscriptions/:id/logs
 func (h *UIHandler) GetLogsJSON(c *gin.Context) {
     id := c.Param("id")
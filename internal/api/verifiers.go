@@ -0,0 +1,226 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+)
+
+// Inbound verification schemes that ship with the service.
+const (
+    VerificationHMACSHA256 = "hmac-sha256"
+    VerificationGitHub     = "github"
+    VerificationStripe     = "stripe"
+    VerificationShopify    = "shopify"
+    VerificationSlack      = "slack"
+)
+
+// SignatureVerifier checks the signature a producer put on an ingested
+// request. Each subscription picks one by name with verification_scheme.
+type SignatureVerifier interface {
+    // Verify checks header against body under any of secrets.
+    Verify(header http.Header, body []byte, secrets []string) (Verification, error)
+    // Sign adds the headers a producer using this scheme sends, so test
+    // requests from the UI pass Verify.
+    Sign(header http.Header, body []byte, secret string, now time.Time)
+    // SignsTimestamp reports whether the signature covers when the request
+    // was sent, which replay protection needs.
+    SignsTimestamp() bool
+}
+
+// Verification is what a verifier learned from a valid request.
+type Verification struct {
+    // SignedAt is when the producer signed the request, zero when the
+    // scheme does not sign a timestamp.
+    SignedAt time.Time
+    // Signature is the signature that matched, used to spot replays.
+    Signature string
+}
+
+// SignatureVerifiers holds the available schemes by name. Register further
+// providers here before the server starts.
+var SignatureVerifiers = map[string]SignatureVerifier{
+    VerificationHMACSHA256: hubVerifier{},
+    VerificationGitHub:     hubVerifier{},
+    VerificationStripe:     stripeVerifier{},
+    VerificationShopify:    shopifyVerifier{},
+    VerificationSlack:      slackVerifier{},
+}
+
+// verifierFor returns the verifier for sub. With replay protection the
+// service's own scheme also signs X-Webhook-Timestamp.
+func verifierFor(sub database.Subscription) (SignatureVerifier, error) {
+    if sub.VerificationScheme == VerificationHMACSHA256 && sub.ReplayProtection {
+        return timestampedHubVerifier{}, nil
+    }
+    v, ok := SignatureVerifiers[sub.VerificationScheme]
+    if !ok {
+        return nil, errors.New("unknown verification scheme " + sub.VerificationScheme)
+    }
+    return v, nil
+}
+
+func hmacSHA256(secret string, parts ...[]byte) []byte {
+    mac := hmac.New(sha256.New, []byte(secret))
+    for _, p := range parts {
+        mac.Write(p)
+    }
+    return mac.Sum(nil)
+}
+
+// matchSignature returns the first of candidates that equals the signature
+// computed by sign under one of secrets.
+func matchSignature(secrets, candidates []string, sign func(secret string) string) (string, bool) {
+    for _, secret := range secrets {
+        expected := sign(secret)
+        for _, candidate := range candidates {
+            if hmac.Equal([]byte(expected), []byte(candidate)) {
+                return candidate, true
+            }
+        }
+    }
+    return "", false
+}
+
+// parseUnix parses a Unix timestamp header value.
+func parseUnix(value, name string) (time.Time, error) {
+    unix, err := strconv.ParseInt(value, 10, 64)
+    if err != nil {
+        return time.Time{}, errors.New("missing or invalid " + name)
+    }
+    return time.Unix(unix, 0), nil
+}
+
+// hubVerifier is X-Hub-Signature-256: "sha256=" and the hex HMAC of the
+// body. This is the service's own scheme and GitHub's.
+type hubVerifier struct{}
+
+func (hubVerifier) Verify(header http.Header, body []byte, secrets []string) (Verification, error) {
+    sig := header.Get("X-Hub-Signature-256")
+    if !verifySignature(body, secrets, sig) {
+        return Verification{}, errInvalidSignature
+    }
+    return Verification{Signature: sig}, nil
+}
+
+func (hubVerifier) Sign(header http.Header, body []byte, secret string, _ time.Time) {
+    header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(hmacSHA256(secret, body)))
+}
+
+func (hubVerifier) SignsTimestamp() bool { return false }
+
+// timestampedHubVerifier is the service's scheme with replay protection:
+// the HMAC covers "<X-Webhook-Timestamp>.<body>".
+type timestampedHubVerifier struct{}
+
+func (timestampedHubVerifier) Verify(header http.Header, body []byte, secrets []string) (Verification, error) {
+    timestamp := header.Get(timestampHeader)
+    signedAt, err := parseUnix(timestamp, timestampHeader)
+    if err != nil {
+        return Verification{}, err
+    }
+    sig := header.Get("X-Hub-Signature-256")
+    if !verifySignature(signedPayload(timestamp, body), secrets, sig) {
+        return Verification{}, errInvalidSignature
+    }
+    return Verification{SignedAt: signedAt, Signature: sig}, nil
+}
+
+func (timestampedHubVerifier) Sign(header http.Header, body []byte, secret string, now time.Time) {
+    timestamp := strconv.FormatInt(now.Unix(), 10)
+    header.Set(timestampHeader, timestamp)
+    hubVerifier{}.Sign(header, signedPayload(timestamp, body), secret, now)
+}
+
+func (timestampedHubVerifier) SignsTimestamp() bool { return true }
+
+// stripeVerifier is Stripe-Signature: "t=<unix>,v1=<hex HMAC of
+// "t.body">", with one v1 entry per active endpoint secret.
+type stripeVerifier struct{}
+
+func (stripeVerifier) Verify(header http.Header, body []byte, secrets []string) (Verification, error) {
+    var timestamp string
+    var candidates []string
+    for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+        key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+        switch key {
+        case "t":
+            timestamp = value
+        case "v1":
+            candidates = append(candidates, value)
+        }
+    }
+    signedAt, err := parseUnix(timestamp, "timestamp in Stripe-Signature")
+    if err != nil {
+        return Verification{}, err
+    }
+    sig, ok := matchSignature(secrets, candidates, func(secret string) string {
+        return hex.EncodeToString(hmacSHA256(secret, []byte(timestamp+"."), body))
+    })
+    if !ok {
+        return Verification{}, errInvalidSignature
+    }
+    return Verification{SignedAt: signedAt, Signature: sig}, nil
+}
+
+func (stripeVerifier) Sign(header http.Header, body []byte, secret string, now time.Time) {
+    timestamp := strconv.FormatInt(now.Unix(), 10)
+    sig := hex.EncodeToString(hmacSHA256(secret, []byte(timestamp+"."), body))
+    header.Set("Stripe-Signature", "t="+timestamp+",v1="+sig)
+}
+
+func (stripeVerifier) SignsTimestamp() bool { return true }
+
+// shopifyVerifier is X-Shopify-Hmac-Sha256: the base64 HMAC of the body.
+type shopifyVerifier struct{}
+
+func (shopifyVerifier) Verify(header http.Header, body []byte, secrets []string) (Verification, error) {
+    sig, ok := matchSignature(secrets, []string{header.Get("X-Shopify-Hmac-Sha256")}, func(secret string) string {
+        return base64.StdEncoding.EncodeToString(hmacSHA256(secret, body))
+    })
+    if !ok {
+        return Verification{}, errInvalidSignature
+    }
+    return Verification{Signature: sig}, nil
+}
+
+func (shopifyVerifier) Sign(header http.Header, body []byte, secret string, _ time.Time) {
+    header.Set("X-Shopify-Hmac-Sha256", base64.StdEncoding.EncodeToString(hmacSHA256(secret, body)))
+}
+
+func (shopifyVerifier) SignsTimestamp() bool { return false }
+
+// slackVerifier is X-Slack-Signature: "v0=" and the hex HMAC of
+// "v0:<X-Slack-Request-Timestamp>:<body>".
+type slackVerifier struct{}
+
+func (slackVerifier) Verify(header http.Header, body []byte, secrets []string) (Verification, error) {
+    timestamp := header.Get("X-Slack-Request-Timestamp")
+    signedAt, err := parseUnix(timestamp, "X-Slack-Request-Timestamp")
+    if err != nil {
+        return Verification{}, err
+    }
+    sig, ok := matchSignature(secrets, []string{header.Get("X-Slack-Signature")}, func(secret string) string {
+        return "v0=" + hex.EncodeToString(hmacSHA256(secret, []byte("v0:"+timestamp+":"), body))
+    })
+    if !ok {
+        return Verification{}, errInvalidSignature
+    }
+    return Verification{SignedAt: signedAt, Signature: sig}, nil
+}
+
+func (slackVerifier) Sign(header http.Header, body []byte, secret string, now time.Time) {
+    timestamp := strconv.FormatInt(now.Unix(), 10)
+    header.Set("X-Slack-Request-Timestamp", timestamp)
+    header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(hmacSHA256(secret, []byte("v0:"+timestamp+":"), body)))
+}
+
+func (slackVerifier) SignsTimestamp() bool { return true }
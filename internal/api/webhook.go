@@ -1,28 +1,50 @@
 package api
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/cache"
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/delivery"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/filter"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type WebhookHandler struct {
-    Queries *database.Queries
-    Cache   *cache.RedisSubscriptionCache
+    DB          *sql.DB
+    Queries     *database.Queries
+    Cache       *cache.RedisSubscriptionCache
+    Idempotency *cache.IdempotencyStore
+    Nonces      *cache.NonceCache
+    // TimestampTolerance is how far X-Webhook-Timestamp may be from now for
+    // subscriptions with replay protection.
+    TimestampTolerance time.Duration
+    // Intake turns away new events during shutdown.
+    Intake *IngestGate
 }
 
+const (
+    idempotencyKeyHeader     = "Idempotency-Key"
+    idempotentReplayedHeader = "Idempotent-Replayed"
+    maxIdempotencyKeyLength  = 255
+)
+
 // RegisterWebhookRoutes registers the webhook ingestion endpoint.
 func RegisterWebhookRoutes(r *gin.Engine, h *WebhookHandler) {
-    r.POST("/ingest/:subscription_id", h.IngestWebhook)
+    r.POST("/ingest/:subscription_id", h.Intake.Guard(), h.IngestWebhook)
 }
 // IngestWebhook handles incoming webhooks.
 func (h *WebhookHandler) IngestWebhook(c *gin.Context) {
@@ -52,39 +74,229 @@ func (h *WebhookHandler) IngestWebhook(c *gin.Context) {
     }
     defer c.Request.Body.Close()
 
-    if !subscriptionAllowsEvent(sub, eventType) {
-        c.Status(http.StatusNoContent)
-        return
-    }
+    idempotencyKey := c.GetHeader(idempotencyKeyHeader)
+    // Keys are scoped to the subscription, and the fingerprint catches a key
+    // reused for a different event.
+    key := subID + ":" + idempotencyKey
+    fingerprint := requestFingerprint(eventType, c.GetHeader(delivery.OrderingKeyHeader), body)
 
+    var nonce string
     if sub.Secret.Valid && sub.Secret.String != "" {
-        sig := c.GetHeader("X-Hub-Signature-256")
-        if !verifySignature(body, sub.Secret.String, sig) {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+        nonce, err = h.verifyIngestRequest(c, sub, body)
+        if errors.Is(err, errSignatureReused) && idempotencyKey != "" && h.Idempotency != nil {
+            // A producer retrying with its Idempotency-Key resends the same
+            // signature: answer from the idempotency store before treating
+            // the request as a replay.
+            stored, lookupErr := h.Idempotency.Lookup(c, key, fingerprint)
+            if h.writeIdempotent(c, stored, lookupErr) {
+                return
+            }
+        }
+        if err != nil {
+            c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
             return
         }
     }
 
-    taskID := uuid.New().String()
-    err = h.Queries.CreateDeliveryTask(c, database.CreateDeliveryTaskParams{
-        ID:             taskID,
-        SubscriptionID: subID,
-        Payload:        string(body),
+    if idempotencyKey == "" || h.Idempotency == nil {
+        status, resp := h.ingestEvent(c, sub, eventType, body, nonce)
+        c.JSON(status, resp)
+        return
+    }
+    if len(idempotencyKey) > maxIdempotencyKeyLength {
+        h.releaseNonce(c, nonce)
+        c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength)})
+        return
+    }
+
+    stored, err := h.Idempotency.Begin(c, key, fingerprint)
+    if err != nil && !errors.Is(err, cache.ErrIdempotencyInProgress) && !errors.Is(err, cache.ErrIdempotencyMismatch) {
+        log.Printf("idempotency store unavailable for subscription %s, ingesting without dedup: %v", subID, err)
+        status, resp := h.ingestEvent(c, sub, eventType, body, nonce)
+        c.JSON(status, resp)
+        return
+    }
+    if h.writeIdempotent(c, stored, err) {
+        return
+    }
+
+    status, resp := h.ingestEvent(c, sub, eventType, body, nonce)
+    encoded, _ := json.Marshal(resp)
+    if status >= http.StatusInternalServerError {
+        // Nothing was queued, so let the producer's retry through.
+        h.Idempotency.Release(c, key)
+    } else if err := h.Idempotency.Complete(c, key, fingerprint, cache.IdempotentResponse{Status: status, Body: encoded}); err != nil {
+        log.Printf("Error storing idempotent response for subscription %s: %v", subID, err)
+        // Do not leave the key claimed: a retry is better answered with a
+        // second delivery than with 409 until the claim expires.
+        h.Idempotency.Release(c, key)
+    }
+    c.Data(status, "application/json; charset=utf-8", encoded)
+}
+
+// writeIdempotent answers a request from the idempotency store's result for
+// its key: the stored response, or the conflict the store reported. It
+// returns false when there is nothing to answer with.
+func (h *WebhookHandler) writeIdempotent(c *gin.Context, stored *cache.IdempotentResponse, err error) bool {
+    switch {
+    case errors.Is(err, cache.ErrIdempotencyInProgress):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    case errors.Is(err, cache.ErrIdempotencyMismatch):
+        c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+    case err == nil && stored != nil:
+        c.Header(idempotentReplayedHeader, "true")
+        c.Data(stored.Status, "application/json; charset=utf-8", stored.Body)
+    default:
+        return false
+    }
+    return true
+}
+
+// ingestEvent queues the event and, when that fails on the server's side,
+// releases the signature nonce so the producer can retry.
+func (h *WebhookHandler) ingestEvent(c *gin.Context, sub database.Subscription, eventType string, body []byte, nonce string) (int, gin.H) {
+    status, resp := h.queueEvent(c, sub, eventType, body)
+    if status >= http.StatusInternalServerError {
+        h.releaseNonce(c, nonce)
+    }
+    return status, resp
+}
+
+// queueEvent stores the event and queues a delivery task for it, or records
+// it as filtered, in one transaction so a failure leaves no event behind for
+// a retry to duplicate. It returns the response to send.
+func (h *WebhookHandler) queueEvent(c *gin.Context, sub database.Subscription, eventType string, body []byte) (int, gin.H) {
+    tx, err := h.DB.BeginTx(c, nil)
+    if err != nil {
+        log.Printf("Error starting transaction for subscription %s: %v", sub.ID, err)
+        return http.StatusInternalServerError, gin.H{"error": "failed to record event"}
+    }
+    defer tx.Rollback()
+    q := h.Queries.WithTx(tx)
+
+    eventID := uuid.New().String()
+    err = q.CreateEvent(c, database.CreateEventParams{
+        ID:        eventID,
+        EventType: nullString(eventType),
+        Payload:   string(body),
+        Source:    eventSource(c, "ingest"),
+        Headers:   eventHeaders(c.Request.Header),
     })
     if err != nil {
-        log.Printf("Error creating delivery task for subscription %s: %v", subID, err)
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue delivery"})
-        return
+        log.Printf("Error storing event for subscription %s: %v", sub.ID, err)
+        return http.StatusInternalServerError, gin.H{"error": "failed to record event"}
     }
 
-    c.Status(http.StatusAccepted)
+    taskID := uuid.New().String()
+    resp := gin.H{"status": "queued", "task_id": taskID, "event_id": eventID}
+    status := http.StatusAccepted
+    if reason := filterReason(sub, eventType, body); reason != "" {
+        if err := recordFiltered(c, q, sub, taskID, eventID, eventType, body, reason); err != nil {
+            log.Printf("Error recording filtered event for subscription %s: %v", sub.ID, err)
+            return http.StatusInternalServerError, gin.H{"error": "failed to record event"}
+        }
+        resp["status"], resp["reason"] = "filtered", reason
+        status = http.StatusOK
+    } else {
+        err = q.CreateDeliveryTask(c, database.CreateDeliveryTaskParams{
+            ID:             taskID,
+            SubscriptionID: sub.ID,
+            Payload:        string(body),
+            OrderingKey:    delivery.OrderingKey(sub, c.GetHeader(delivery.OrderingKeyHeader)),
+            EventType:      nullString(eventType),
+            EventID:        nullString(eventID),
+        })
+        if err != nil {
+            log.Printf("Error creating delivery task for subscription %s: %v", sub.ID, err)
+            return http.StatusInternalServerError, gin.H{"error": "failed to queue delivery"}
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        log.Printf("Error committing event for subscription %s: %v", sub.ID, err)
+        return http.StatusInternalServerError, gin.H{"error": "failed to record event"}
+    }
+    return status, resp
 }
 
-func verifySignature(body []byte, secret, signature string) bool {
-    mac := hmac.New(sha256.New, []byte(secret))
-    mac.Write(body)
-    expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
-    return hmac.Equal([]byte(expected), []byte(signature))
+// requestFingerprint identifies an ingest request for idempotency checks.
+func requestFingerprint(eventType, orderingKey string, body []byte) string {
+    h := sha256.New()
+    h.Write([]byte(eventType))
+    h.Write([]byte{0})
+    h.Write([]byte(orderingKey))
+    h.Write([]byte{0})
+    h.Write(body)
+    return hex.EncodeToString(h.Sum(nil))
+}
+
+// verifySignature reports whether signature matches body under any of
+// secrets, so both secrets work during a rotation.
+func verifySignature(body []byte, secrets []string, signature string) bool {
+    valid := false
+    for _, secret := range secrets {
+        mac := hmac.New(sha256.New, []byte(secret))
+        mac.Write(body)
+        expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+        if hmac.Equal([]byte(expected), []byte(signature)) {
+            valid = true
+        }
+    }
+    return valid
+}
+
+// filterReason returns why sub does not take this event, or "" when it
+// should be delivered.
+func filterReason(sub database.Subscription, eventType string, body []byte) string {
+    if !subscriptionAllowsEvent(sub, eventType) {
+        return fmt.Sprintf("event type %q is not subscribed", eventType)
+    }
+    return payloadFilterReason(sub, body)
+}
+
+// payloadFilterReason evaluates the subscription's filter expression against
+// body and returns why it rejected it, or "" when it matched.
+func payloadFilterReason(sub database.Subscription, body []byte) string {
+    if !sub.Filter.Valid || sub.Filter.String == "" {
+        return ""
+    }
+    expr, err := filter.Parse(sub.Filter.String)
+    if err != nil {
+        return "invalid filter: " + err.Error()
+    }
+    matched, err := expr.Match(body)
+    if err != nil {
+        return "filter not evaluated: " + err.Error()
+    }
+    if !matched {
+        return "payload does not match filter"
+    }
+    return ""
+}
+
+// recordFiltered stores a rejected event as a filtered task with a log entry
+// giving the reason, so it shows up in the delivery logs.
+func recordFiltered(ctx context.Context, q *database.Queries, sub database.Subscription, taskID, eventID, eventType string, body []byte, reason string) error {
+    err := q.CreateFilteredDeliveryTask(ctx, database.CreateFilteredDeliveryTaskParams{
+        ID:             taskID,
+        SubscriptionID: sub.ID,
+        Payload:        string(body),
+        EventType:      nullString(eventType),
+        EventID:        nullString(eventID),
+    })
+    if err != nil {
+        return err
+    }
+    return q.CreateDeliveryLog(ctx, database.CreateDeliveryLogParams{
+        ID:             uuid.New().String(),
+        DeliveryTaskID: taskID,
+        SubscriptionID: sub.ID,
+        TargetUrl:      sub.TargetUrl,
+        Timestamp:      time.Now(),
+        AttemptNumber:  0,
+        Outcome:        "filtered",
+        ErrorDetails:   nullString(reason),
+        Classification: nullString("filtered"),
+    })
 }
 
 func subscriptionAllowsEvent(sub database.Subscription, eventType string) bool {
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrIdempotencyInProgress is returned while the first request with a key
+// has not finished yet.
+var ErrIdempotencyInProgress = errors.New("a request with this Idempotency-Key is still being processed")
+
+// ErrIdempotencyMismatch is returned when a key is reused for a different
+// request.
+var ErrIdempotencyMismatch = errors.New("Idempotency-Key was already used for a different request")
+
+// IdempotentResponse is the response stored for an Idempotency-Key and
+// replayed to repeats of the request.
+type IdempotentResponse struct {
+    Status int             `json:"status"`
+    Body   json.RawMessage `json:"body"`
+}
+
+type idempotencyRecord struct {
+    Fingerprint string              `json:"fingerprint"`
+    Response    *IdempotentResponse `json:"response,omitempty"`
+}
+
+// idempotencyPendingTTL bounds how long a key stays claimed by a request
+// that never finished, e.g. because its instance died. Ingesting an event
+// takes far less than this.
+const idempotencyPendingTTL = time.Minute
+
+// IdempotencyStore remembers the response to each Idempotency-Key for a
+// dedup window, shared by every service instance.
+type IdempotencyStore struct {
+    client *redis.Client
+    ttl    time.Duration
+}
+
+// IdempotencyStore returns a store on the cache's Redis connection that
+// keeps keys for ttl.
+func (c *RedisSubscriptionCache) IdempotencyStore(ttl time.Duration) *IdempotencyStore {
+    if c == nil || c.client == nil {
+        return nil
+    }
+    return &IdempotencyStore{client: c.client, ttl: ttl}
+}
+
+// Begin claims key for a request identified by fingerprint. It returns
+// (nil, nil) when the caller is first and should process the request, and
+// the stored response when the request was already processed. A request
+// still in progress, or one with a different fingerprint, is an error. The
+// claim only lasts idempotencyPendingTTL; Complete keeps the key for the
+// full dedup window.
+func (s *IdempotencyStore) Begin(ctx context.Context, key, fingerprint string) (*IdempotentResponse, error) {
+    pending, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+    claimed, err := s.client.SetNX(ctx, "idempotency:"+key, pending, idempotencyPendingTTL).Result()
+    if err != nil {
+        return nil, err
+    }
+    if claimed {
+        return nil, nil
+    }
+
+    stored, err := s.Lookup(ctx, key, fingerprint)
+    if err == nil && stored == nil {
+        // Expired or released in between: try again.
+        return s.Begin(ctx, key, fingerprint)
+    }
+    return stored, err
+}
+
+// Lookup returns the stored response for key without claiming it, or nil
+// when the key is unused. Like Begin, it fails for a request that is still
+// in progress or has a different fingerprint.
+func (s *IdempotencyStore) Lookup(ctx context.Context, key, fingerprint string) (*IdempotentResponse, error) {
+    val, err := s.client.Get(ctx, "idempotency:"+key).Result()
+    if err == redis.Nil {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var record idempotencyRecord
+    if err := json.Unmarshal([]byte(val), &record); err != nil {
+        return nil, err
+    }
+    if record.Fingerprint != fingerprint {
+        return nil, ErrIdempotencyMismatch
+    }
+    if record.Response == nil {
+        return nil, ErrIdempotencyInProgress
+    }
+    return record.Response, nil
+}
+
+// Complete stores the response for key so repeats get the same answer.
+func (s *IdempotencyStore) Complete(ctx context.Context, key, fingerprint string, resp IdempotentResponse) error {
+    b, err := json.Marshal(idempotencyRecord{Fingerprint: fingerprint, Response: &resp})
+    if err != nil {
+        return err
+    }
+    return s.client.Set(ctx, "idempotency:"+key, b, s.ttl).Err()
+}
+
+// Release forgets key, e.g. after the request failed, so a retry is
+// processed again.
+func (s *IdempotencyStore) Release(ctx context.Context, key string) {
+    s.client.Del(ctx, "idempotency:"+key)
+}
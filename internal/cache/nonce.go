@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NonceCache remembers values that may be used only once, such as the
+// signatures of ingested requests, across all service instances.
+type NonceCache struct {
+    client *redis.Client
+}
+
+// NonceCache returns a nonce cache on the cache's Redis connection.
+func (c *RedisSubscriptionCache) NonceCache() *NonceCache {
+    if c == nil || c.client == nil {
+        return nil
+    }
+    return &NonceCache{client: c.client}
+}
+
+// Claim records nonce for ttl. It returns false when nonce was already
+// claimed within its ttl.
+func (n *NonceCache) Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+    return n.client.SetNX(ctx, "nonce:"+nonce, 1, ttl).Result()
+}
+
+// Release forgets nonce so it can be claimed again, e.g. after the request
+// it guarded failed before anything was stored.
+func (n *NonceCache) Release(ctx context.Context, nonce string) {
+    n.client.Del(ctx, "nonce:"+nonce)
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills the bucket for the time elapsed since the last
+// call and takes one token if available. It returns {allowed, wait_ms}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+    tokens = burst
+    ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+else
+    wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, wait}
+`)
+
+// RedisRateLimiter is a token bucket whose state lives in Redis, so the limit
+// holds across every service instance.
+type RedisRateLimiter struct {
+    client *redis.Client
+}
+
+// RateLimiter returns a limiter that shares the cache's Redis connection.
+func (c *RedisSubscriptionCache) RateLimiter() *RedisRateLimiter {
+    if c == nil || c.client == nil {
+        return nil
+    }
+    return &RedisRateLimiter{client: c.client}
+}
+
+// Allow takes a token from the bucket identified by key, which refills at
+// ratePerSecond up to burst tokens. When no token is available it returns
+// false and how long until one will be.
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string, ratePerSecond float64, burst int) (bool, time.Duration, error) {
+    if l == nil || ratePerSecond <= 0 {
+        return true, 0, nil
+    }
+    if burst < 1 {
+        burst = int(math.Max(1, math.Ceil(ratePerSecond)))
+    }
+    res, err := tokenBucketScript.Run(ctx, l.client, []string{"ratelimit:" + key},
+        ratePerSecond, burst, time.Now().UnixMilli()).Int64Slice()
+    if err != nil {
+        return true, 0, err
+    }
+    return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
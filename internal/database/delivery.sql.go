@@ -11,23 +11,238 @@ import (
 	"time"
 )
 
+const claimDeliveryBatch = `-- name: ClaimDeliveryBatch :many
+UPDATE delivery_tasks
+SET status = 'in_flight', lease_owner = ?, lease_expires_at = ?
+WHERE id IN (
+    SELECT due.id FROM (
+        SELECT
+            t.id,
+            t.created_at,
+            t.rowid AS seq,
+            COUNT(*) OVER () AS due_count,
+            MIN(t.created_at) OVER () AS oldest_created_at
+        FROM delivery_tasks t
+        WHERE t.subscription_id = ?
+          AND (
+              (t.status = 'pending' AND (t.next_attempt_at IS NULL OR t.next_attempt_at <= ?))
+              OR (t.status = 'in_flight' AND t.lease_expires_at <= ?)
+          )
+          AND (
+              t.ordering_key IS NULL OR NOT EXISTS (
+                  SELECT 1 FROM delivery_tasks earlier
+                  WHERE earlier.subscription_id = t.subscription_id
+                    AND earlier.ordering_key = t.ordering_key
+                    AND earlier.status IN ('pending', 'in_flight')
+                    AND (earlier.created_at < t.created_at
+                         OR (earlier.created_at = t.created_at AND earlier.rowid < t.rowid))
+              )
+          )
+          AND NOT EXISTS (
+              SELECT 1 FROM delivery_tasks busy
+              WHERE busy.subscription_id = t.subscription_id
+                AND busy.status = 'in_flight'
+                AND busy.lease_expires_at > ?
+          )
+    ) due
+    WHERE due.due_count >= ?
+       OR datetime(due.oldest_created_at) <= datetime(?)
+    ORDER BY due.created_at ASC, due.seq ASC
+    LIMIT ?
+)
+RETURNING id, subscription_id, payload, created_at, status, last_attempt_at, attempt_count, next_attempt_at, lease_owner, lease_expires_at, ordering_key, event_type, event_id
+`
+
+type ClaimDeliveryBatchParams struct {
+	LeaseOwner     sql.NullString
+	LeaseExpiresAt sql.NullTime
+	SubscriptionID string
+	Now            sql.NullTime
+	BatchSize      int64
+	ReadyBefore    interface{}
+}
+
+// Claims the next batch of a batching subscription: up to batch_size due
+// tasks, once there are batch_size of them or the oldest was created before
+// ready_before. Nothing is claimed while a batch of the subscription is still
+// in flight.
+func (q *Queries) ClaimDeliveryBatch(ctx context.Context, arg ClaimDeliveryBatchParams) ([]DeliveryTask, error) {
+	rows, err := q.db.QueryContext(ctx, claimDeliveryBatch,
+		arg.LeaseOwner,
+		arg.LeaseExpiresAt,
+		arg.SubscriptionID,
+		arg.Now,
+		arg.Now,
+		arg.Now,
+		arg.BatchSize,
+		arg.ReadyBefore,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeliveryTask
+	for rows.Next() {
+		var i DeliveryTask
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.Payload,
+			&i.CreatedAt,
+			&i.Status,
+			&i.LastAttemptAt,
+			&i.AttemptCount,
+			&i.NextAttemptAt,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.OrderingKey,
+			&i.EventType,
+			&i.EventID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimDeliveryTasks = `-- name: ClaimDeliveryTasks :many
+UPDATE delivery_tasks
+SET status = 'in_flight', lease_owner = ?, lease_expires_at = ?
+WHERE id IN (
+    SELECT candidate.id FROM (
+        SELECT
+            t.id,
+            t.created_at,
+            ROW_NUMBER() OVER (PARTITION BY t.subscription_id ORDER BY t.created_at) AS position,
+            (
+                SELECT COUNT(*) FROM delivery_tasks busy
+                WHERE busy.subscription_id = t.subscription_id
+                  AND busy.status = 'in_flight'
+                  AND busy.lease_expires_at > ?
+            ) AS busy_count
+        FROM delivery_tasks t
+        WHERE (
+            (t.status = 'pending' AND (t.next_attempt_at IS NULL OR t.next_attempt_at <= ?))
+            OR (t.status = 'in_flight' AND t.lease_expires_at <= ?)
+        )
+        AND (
+            t.ordering_key IS NULL OR NOT EXISTS (
+                SELECT 1 FROM delivery_tasks earlier
+                WHERE earlier.subscription_id = t.subscription_id
+                  AND earlier.ordering_key = t.ordering_key
+                  AND earlier.status IN ('pending', 'in_flight')
+                  AND (earlier.created_at < t.created_at
+                       OR (earlier.created_at = t.created_at AND earlier.rowid < t.rowid))
+            )
+        )
+        AND NOT EXISTS (
+            SELECT 1 FROM subscriptions s
+            WHERE s.id = t.subscription_id AND s.batch_max_size > 1
+        )
+    ) candidate
+    WHERE candidate.position + candidate.busy_count <= ?
+    ORDER BY candidate.created_at ASC
+    LIMIT ?
+)
+RETURNING id, subscription_id, payload, created_at, status, last_attempt_at, attempt_count, next_attempt_at, lease_owner, lease_expires_at, ordering_key, event_type, event_id
+`
+
+type ClaimDeliveryTasksParams struct {
+	LeaseOwner         sql.NullString
+	LeaseExpiresAt     sql.NullTime
+	Now                sql.NullTime
+	MaxPerSubscription int64
+	BatchSize          int64
+}
+
+// Claims up to batch_size due tasks, taking no more per subscription than
+// max_per_subscription minus what is already in flight for it. A task with an
+// ordering key is only claimable once every earlier task in its stream has
+// been delivered or dead-lettered. Batching subscriptions are claimed by
+// ClaimDeliveryBatch instead.
+func (q *Queries) ClaimDeliveryTasks(ctx context.Context, arg ClaimDeliveryTasksParams) ([]DeliveryTask, error) {
+	rows, err := q.db.QueryContext(ctx, claimDeliveryTasks,
+		arg.LeaseOwner,
+		arg.LeaseExpiresAt,
+		arg.Now,
+		arg.Now,
+		arg.Now,
+		arg.MaxPerSubscription,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeliveryTask
+	for rows.Next() {
+		var i DeliveryTask
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.Payload,
+			&i.CreatedAt,
+			&i.Status,
+			&i.LastAttemptAt,
+			&i.AttemptCount,
+			&i.NextAttemptAt,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.OrderingKey,
+			&i.EventType,
+			&i.EventID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createDeliveryLog = `-- name: CreateDeliveryLog :exec
 INSERT INTO delivery_logs (
     id, delivery_task_id, subscription_id, target_url, timestamp,
-    attempt_number, outcome, http_status, error_details
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+    attempt_number, outcome, http_status, error_details, signature_scheme,
+    classification, batch_id, latency_ms, response_body, response_headers,
+    dns_ms, connect_ms, tls_ms, ttfb_ms, connection_reused
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 type CreateDeliveryLogParams struct {
-	ID             string
-	DeliveryTaskID string
-	SubscriptionID string
-	TargetUrl      string
-	Timestamp      time.Time
-	AttemptNumber  int64
-	Outcome        string
-	HttpStatus     sql.NullInt64
-	ErrorDetails   sql.NullString
+	ID               string
+	DeliveryTaskID   string
+	SubscriptionID   string
+	TargetUrl        string
+	Timestamp        time.Time
+	AttemptNumber    int64
+	Outcome          string
+	HttpStatus       sql.NullInt64
+	ErrorDetails     sql.NullString
+	SignatureScheme  sql.NullString
+	Classification   sql.NullString
+	BatchID          sql.NullString
+	LatencyMs        sql.NullInt64
+	ResponseBody     sql.NullString
+	ResponseHeaders  sql.NullString
+	DnsMs            sql.NullInt64
+	ConnectMs        sql.NullInt64
+	TlsMs            sql.NullInt64
+	TtfbMs           sql.NullInt64
+	ConnectionReused sql.NullBool
 }
 
 func (q *Queries) CreateDeliveryLog(ctx context.Context, arg CreateDeliveryLogParams) error {
@@ -41,26 +256,93 @@ func (q *Queries) CreateDeliveryLog(ctx context.Context, arg CreateDeliveryLogPa
 		arg.Outcome,
 		arg.HttpStatus,
 		arg.ErrorDetails,
+		arg.SignatureScheme,
+		arg.Classification,
+		arg.BatchID,
+		arg.LatencyMs,
+		arg.ResponseBody,
+		arg.ResponseHeaders,
+		arg.DnsMs,
+		arg.ConnectMs,
+		arg.TlsMs,
+		arg.TtfbMs,
+		arg.ConnectionReused,
 	)
 	return err
 }
 
 const createDeliveryTask = `-- name: CreateDeliveryTask :exec
-INSERT INTO delivery_tasks (id, subscription_id, payload, status, attempt_count, created_at)
-VALUES (?, ?, ?, 'pending', 0, CURRENT_TIMESTAMP)
+INSERT INTO delivery_tasks (id, subscription_id, payload, ordering_key, event_type, event_id, status, attempt_count, created_at)
+VALUES (?, ?, ?, ?, ?, ?, 'pending', 0, CURRENT_TIMESTAMP)
 `
 
 type CreateDeliveryTaskParams struct {
 	ID             string
 	SubscriptionID string
 	Payload        string
+	OrderingKey    sql.NullString
+	EventType      sql.NullString
+	EventID        sql.NullString
 }
 
 func (q *Queries) CreateDeliveryTask(ctx context.Context, arg CreateDeliveryTaskParams) error {
-	_, err := q.db.ExecContext(ctx, createDeliveryTask, arg.ID, arg.SubscriptionID, arg.Payload)
+	_, err := q.db.ExecContext(ctx, createDeliveryTask,
+		arg.ID,
+		arg.SubscriptionID,
+		arg.Payload,
+		arg.OrderingKey,
+		arg.EventType,
+		arg.EventID,
+	)
+	return err
+}
+
+const createFilteredDeliveryTask = `-- name: CreateFilteredDeliveryTask :exec
+INSERT INTO delivery_tasks (id, subscription_id, payload, event_type, event_id, status, attempt_count, created_at)
+VALUES (?, ?, ?, ?, ?, 'filtered', 0, CURRENT_TIMESTAMP)
+`
+
+type CreateFilteredDeliveryTaskParams struct {
+	ID             string
+	SubscriptionID string
+	Payload        string
+	EventType      sql.NullString
+	EventID        sql.NullString
+}
+
+// Records an event the subscription's filters rejected. It is never claimed.
+func (q *Queries) CreateFilteredDeliveryTask(ctx context.Context, arg CreateFilteredDeliveryTaskParams) error {
+	_, err := q.db.ExecContext(ctx, createFilteredDeliveryTask,
+		arg.ID,
+		arg.SubscriptionID,
+		arg.Payload,
+		arg.EventType,
+		arg.EventID,
+	)
 	return err
 }
 
+const deferDeliveryTask = `-- name: DeferDeliveryTask :execrows
+UPDATE delivery_tasks
+SET status = 'pending', next_attempt_at = ?, lease_owner = NULL, lease_expires_at = NULL
+WHERE id = ? AND lease_owner = ?
+`
+
+type DeferDeliveryTaskParams struct {
+	NextAttemptAt sql.NullTime
+	ID            string
+	LeaseOwner    sql.NullString
+}
+
+// Hands a claimed task back to the queue without counting an attempt.
+func (q *Queries) DeferDeliveryTask(ctx context.Context, arg DeferDeliveryTaskParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deferDeliveryTask, arg.NextAttemptAt, arg.ID, arg.LeaseOwner)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteOldDeliveryLogs = `-- name: DeleteOldDeliveryLogs :exec
 DELETE FROM delivery_logs
 WHERE timestamp < datetime('now', '-72 hours')
@@ -72,7 +354,7 @@ func (q *Queries) DeleteOldDeliveryLogs(ctx context.Context) error {
 }
 
 const getDeliveryTask = `-- name: GetDeliveryTask :one
-SELECT id, subscription_id, payload, created_at, status, last_attempt_at, attempt_count, next_attempt_at FROM delivery_tasks WHERE id = ?
+SELECT id, subscription_id, payload, created_at, status, last_attempt_at, attempt_count, next_attempt_at, lease_owner, lease_expires_at, ordering_key, event_type, event_id FROM delivery_tasks WHERE id = ?
 `
 
 func (q *Queries) GetDeliveryTask(ctx context.Context, id string) (DeliveryTask, error) {
@@ -87,12 +369,17 @@ func (q *Queries) GetDeliveryTask(ctx context.Context, id string) (DeliveryTask,
 		&i.LastAttemptAt,
 		&i.AttemptCount,
 		&i.NextAttemptAt,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+		&i.OrderingKey,
+		&i.EventType,
+		&i.EventID,
 	)
 	return i, err
 }
 
 const listDeliveryLogsForTask = `-- name: ListDeliveryLogsForTask :many
-SELECT id, delivery_task_id, subscription_id, target_url, timestamp, attempt_number, outcome, http_status, error_details FROM delivery_logs
+SELECT id, delivery_task_id, subscription_id, target_url, timestamp, attempt_number, outcome, http_status, error_details, signature_scheme, classification, batch_id, latency_ms, response_body, response_headers, dns_ms, connect_ms, tls_ms, ttfb_ms, connection_reused FROM delivery_logs
 WHERE delivery_task_id = ?
 ORDER BY attempt_number ASC
 `
@@ -116,6 +403,17 @@ func (q *Queries) ListDeliveryLogsForTask(ctx context.Context, deliveryTaskID st
 			&i.Outcome,
 			&i.HttpStatus,
 			&i.ErrorDetails,
+			&i.SignatureScheme,
+			&i.Classification,
+			&i.BatchID,
+			&i.LatencyMs,
+			&i.ResponseBody,
+			&i.ResponseHeaders,
+			&i.DnsMs,
+			&i.ConnectMs,
+			&i.TlsMs,
+			&i.TtfbMs,
+			&i.ConnectionReused,
 		); err != nil {
 			return nil, err
 		}
@@ -130,15 +428,14 @@ func (q *Queries) ListDeliveryLogsForTask(ctx context.Context, deliveryTaskID st
 	return items, nil
 }
 
-const listPendingDeliveryTasks = `-- name: ListPendingDeliveryTasks :many
-SELECT id, subscription_id, payload, created_at, status, last_attempt_at, attempt_count, next_attempt_at FROM delivery_tasks
-WHERE status = 'pending' AND (next_attempt_at IS NULL OR next_attempt_at <= CURRENT_TIMESTAMP)
-ORDER BY created_at ASC
-LIMIT 10
+const listDeliveryTasksForEvent = `-- name: ListDeliveryTasksForEvent :many
+SELECT id, subscription_id, payload, created_at, status, last_attempt_at, attempt_count, next_attempt_at, lease_owner, lease_expires_at, ordering_key, event_type, event_id FROM delivery_tasks
+WHERE event_id = ?
+ORDER BY created_at ASC, rowid ASC
 `
 
-func (q *Queries) ListPendingDeliveryTasks(ctx context.Context) ([]DeliveryTask, error) {
-	rows, err := q.db.QueryContext(ctx, listPendingDeliveryTasks)
+func (q *Queries) ListDeliveryTasksForEvent(ctx context.Context, eventID sql.NullString) ([]DeliveryTask, error) {
+	rows, err := q.db.QueryContext(ctx, listDeliveryTasksForEvent, eventID)
 	if err != nil {
 		return nil, err
 	}
@@ -155,6 +452,11 @@ func (q *Queries) ListPendingDeliveryTasks(ctx context.Context) ([]DeliveryTask,
 			&i.LastAttemptAt,
 			&i.AttemptCount,
 			&i.NextAttemptAt,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.OrderingKey,
+			&i.EventType,
+			&i.EventID,
 		); err != nil {
 			return nil, err
 		}
@@ -180,6 +482,10 @@ SELECT
     dl.outcome,
     dl.http_status,
     dl.error_details,
+    dl.classification,
+    dl.latency_ms,
+    dl.response_body,
+    dl.response_headers,
     dt.status AS task_status
 FROM delivery_logs dl
 LEFT JOIN delivery_tasks dt ON dl.delivery_task_id = dt.id
@@ -189,16 +495,20 @@ LIMIT 20
 `
 
 type ListRecentDeliveryLogsForSubscriptionRow struct {
-	ID             string
-	DeliveryTaskID string
-	SubscriptionID string
-	TargetUrl      string
-	Timestamp      time.Time
-	AttemptNumber  int64
-	Outcome        string
-	HttpStatus     sql.NullInt64
-	ErrorDetails   sql.NullString
-	TaskStatus     sql.NullString
+	ID              string
+	DeliveryTaskID  string
+	SubscriptionID  string
+	TargetUrl       string
+	Timestamp       time.Time
+	AttemptNumber   int64
+	Outcome         string
+	HttpStatus      sql.NullInt64
+	ErrorDetails    sql.NullString
+	Classification  sql.NullString
+	LatencyMs       sql.NullInt64
+	ResponseBody    sql.NullString
+	ResponseHeaders sql.NullString
+	TaskStatus      sql.NullString
 }
 
 func (q *Queries) ListRecentDeliveryLogsForSubscription(ctx context.Context, subscriptionID string) ([]ListRecentDeliveryLogsForSubscriptionRow, error) {
@@ -220,6 +530,10 @@ func (q *Queries) ListRecentDeliveryLogsForSubscription(ctx context.Context, sub
 			&i.Outcome,
 			&i.HttpStatus,
 			&i.ErrorDetails,
+			&i.Classification,
+			&i.LatencyMs,
+			&i.ResponseBody,
+			&i.ResponseHeaders,
 			&i.TaskStatus,
 		); err != nil {
 			return nil, err
@@ -235,41 +549,34 @@ func (q *Queries) ListRecentDeliveryLogsForSubscription(ctx context.Context, sub
 	return items, nil
 }
 
-const updateDeliveryTaskNextAttemptAt = `-- name: UpdateDeliveryTaskNextAttemptAt :exec
+const updateDeliveryTaskStatus = `-- name: UpdateDeliveryTaskStatus :execrows
 UPDATE delivery_tasks
-SET next_attempt_at = ?
-WHERE id = ?
-`
-
-type UpdateDeliveryTaskNextAttemptAtParams struct {
-	NextAttemptAt sql.NullTime
-	ID            string
-}
-
-func (q *Queries) UpdateDeliveryTaskNextAttemptAt(ctx context.Context, arg UpdateDeliveryTaskNextAttemptAtParams) error {
-	_, err := q.db.ExecContext(ctx, updateDeliveryTaskNextAttemptAt, arg.NextAttemptAt, arg.ID)
-	return err
-}
-
-const updateDeliveryTaskStatus = `-- name: UpdateDeliveryTaskStatus :exec
-UPDATE delivery_tasks
-SET status = ?, last_attempt_at = ?, attempt_count = ?
-WHERE id = ?
+SET status = ?, last_attempt_at = ?, attempt_count = ?, next_attempt_at = ?, lease_owner = NULL, lease_expires_at = NULL
+WHERE id = ? AND lease_owner = ?
 `
 
 type UpdateDeliveryTaskStatusParams struct {
 	Status        string
 	LastAttemptAt sql.NullTime
 	AttemptCount  int64
+	NextAttemptAt sql.NullTime
 	ID            string
+	LeaseOwner    sql.NullString
 }
 
-func (q *Queries) UpdateDeliveryTaskStatus(ctx context.Context, arg UpdateDeliveryTaskStatusParams) error {
-	_, err := q.db.ExecContext(ctx, updateDeliveryTaskStatus,
+// Records an attempt's outcome, schedules the next one and releases the
+// lease, all only while the caller still holds the lease.
+func (q *Queries) UpdateDeliveryTaskStatus(ctx context.Context, arg UpdateDeliveryTaskStatusParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateDeliveryTaskStatus,
 		arg.Status,
 		arg.LastAttemptAt,
 		arg.AttemptCount,
+		arg.NextAttemptAt,
 		arg.ID,
+		arg.LeaseOwner,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
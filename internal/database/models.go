@@ -25,15 +25,26 @@ type DeadLetterTask struct {
 }
 
 type DeliveryLog struct {
-	ID             string
-	DeliveryTaskID string
-	SubscriptionID string
-	TargetUrl      string
-	Timestamp      time.Time
-	AttemptNumber  int64
-	Outcome        string
-	HttpStatus     sql.NullInt64
-	ErrorDetails   sql.NullString
+	ID               string
+	DeliveryTaskID   string
+	SubscriptionID   string
+	TargetUrl        string
+	Timestamp        time.Time
+	AttemptNumber    int64
+	Outcome          string
+	HttpStatus       sql.NullInt64
+	ErrorDetails     sql.NullString
+	SignatureScheme  sql.NullString
+	Classification   sql.NullString
+	BatchID          sql.NullString
+	LatencyMs        sql.NullInt64
+	ResponseBody     sql.NullString
+	ResponseHeaders  sql.NullString
+	DnsMs            sql.NullInt64
+	ConnectMs        sql.NullInt64
+	TlsMs            sql.NullInt64
+	TtfbMs           sql.NullInt64
+	ConnectionReused sql.NullBool
 }
 
 type DeliveryTask struct {
@@ -45,6 +56,20 @@ type DeliveryTask struct {
 	LastAttemptAt  sql.NullTime
 	AttemptCount   int64
 	NextAttemptAt  sql.NullTime
+	LeaseOwner     sql.NullString
+	LeaseExpiresAt sql.NullTime
+	OrderingKey    sql.NullString
+	EventType      sql.NullString
+	EventID        sql.NullString
+}
+
+type Event struct {
+	ID         string
+	EventType  sql.NullString
+	Payload    string
+	Source     string
+	Headers    sql.NullString
+	ReceivedAt time.Time
 }
 
 type ScheduledWebhook struct {
@@ -58,11 +83,43 @@ type ScheduledWebhook struct {
 	UpdatedAt      time.Time
 }
 
+type SecretRotation struct {
+	ID                      string
+	SubscriptionID          string
+	RotatedAt               time.Time
+	PreviousSecretExpiresAt sql.NullTime
+}
+
 type Subscription struct {
-	ID         string
-	TargetUrl  string
-	Secret     sql.NullString
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	EventTypes sql.NullString
+	ID                      string
+	TargetUrl               string
+	Secret                  sql.NullString
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	EventTypes              sql.NullString
+	MaxAttempts             sql.NullInt64
+	RetryBaseDelaySeconds   sql.NullInt64
+	RetryMaxDelaySeconds    sql.NullInt64
+	RetryMultiplier         sql.NullFloat64
+	RetryJitter             sql.NullFloat64
+	RetrySchedule           sql.NullString
+	RateLimitPerSecond      sql.NullFloat64
+	RateLimitBurst          sql.NullInt64
+	Ordered                 bool
+	BatchMaxSize            sql.NullInt64
+	BatchMaxWaitSeconds     sql.NullInt64
+	Headers                 sql.NullString
+	TlsClientCert           sql.NullString
+	TlsClientKeyEncrypted   sql.NullString
+	TlsCaBundle             sql.NullString
+	TlsPinnedCertSha256     sql.NullString
+	AuthType                sql.NullString
+	AuthConfigEncrypted     sql.NullString
+	Transform               sql.NullString
+	Filter                  sql.NullString
+	SigningProfile          string
+	PreviousSecret          sql.NullString
+	PreviousSecretExpiresAt sql.NullTime
+	ReplayProtection        bool
+	VerificationScheme      string
 }
@@ -10,16 +10,54 @@ import (
 	"database/sql"
 )
 
+const createSecretRotation = `-- name: CreateSecretRotation :exec
+INSERT INTO secret_rotations (id, subscription_id, previous_secret_expires_at)
+VALUES (?, ?, ?)
+`
+
+type CreateSecretRotationParams struct {
+	ID                      string
+	SubscriptionID          string
+	PreviousSecretExpiresAt sql.NullTime
+}
+
+func (q *Queries) CreateSecretRotation(ctx context.Context, arg CreateSecretRotationParams) error {
+	_, err := q.db.ExecContext(ctx, createSecretRotation, arg.ID, arg.SubscriptionID, arg.PreviousSecretExpiresAt)
+	return err
+}
+
 const createSubscription = `-- name: CreateSubscription :exec
-INSERT INTO subscriptions (id, target_url, secret, event_types)
-VALUES (?, ?, ?, ?)
+INSERT INTO subscriptions (
+    id, target_url, secret, event_types,
+    max_attempts, retry_base_delay_seconds, retry_max_delay_seconds, retry_multiplier, retry_jitter, retry_schedule,
+    rate_limit_per_second, rate_limit_burst, ordered, batch_max_size, batch_max_wait_seconds,
+    headers, transform, filter, signing_profile, replay_protection, verification_scheme
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 type CreateSubscriptionParams struct {
-	ID         string
-	TargetUrl  string
-	Secret     sql.NullString
-	EventTypes sql.NullString
+	ID                    string
+	TargetUrl             string
+	Secret                sql.NullString
+	EventTypes            sql.NullString
+	MaxAttempts           sql.NullInt64
+	RetryBaseDelaySeconds sql.NullInt64
+	RetryMaxDelaySeconds  sql.NullInt64
+	RetryMultiplier       sql.NullFloat64
+	RetryJitter           sql.NullFloat64
+	RetrySchedule         sql.NullString
+	RateLimitPerSecond    sql.NullFloat64
+	RateLimitBurst        sql.NullInt64
+	Ordered               bool
+	BatchMaxSize          sql.NullInt64
+	BatchMaxWaitSeconds   sql.NullInt64
+	Headers               sql.NullString
+	Transform             sql.NullString
+	Filter                sql.NullString
+	SigningProfile        string
+	ReplayProtection      bool
+	VerificationScheme    string
 }
 
 func (q *Queries) CreateSubscription(ctx context.Context, arg CreateSubscriptionParams) error {
@@ -28,6 +66,23 @@ func (q *Queries) CreateSubscription(ctx context.Context, arg CreateSubscription
 		arg.TargetUrl,
 		arg.Secret,
 		arg.EventTypes,
+		arg.MaxAttempts,
+		arg.RetryBaseDelaySeconds,
+		arg.RetryMaxDelaySeconds,
+		arg.RetryMultiplier,
+		arg.RetryJitter,
+		arg.RetrySchedule,
+		arg.RateLimitPerSecond,
+		arg.RateLimitBurst,
+		arg.Ordered,
+		arg.BatchMaxSize,
+		arg.BatchMaxWaitSeconds,
+		arg.Headers,
+		arg.Transform,
+		arg.Filter,
+		arg.SigningProfile,
+		arg.ReplayProtection,
+		arg.VerificationScheme,
 	)
 	return err
 }
@@ -42,7 +97,7 @@ func (q *Queries) DeleteSubscription(ctx context.Context, id string) error {
 }
 
 const getSubscription = `-- name: GetSubscription :one
-SELECT id, target_url, secret, created_at, updated_at, event_types FROM subscriptions WHERE id = ?
+SELECT id, target_url, secret, created_at, updated_at, event_types, max_attempts, retry_base_delay_seconds, retry_max_delay_seconds, retry_multiplier, retry_jitter, retry_schedule, rate_limit_per_second, rate_limit_burst, ordered, batch_max_size, batch_max_wait_seconds, headers, tls_client_cert, tls_client_key_encrypted, tls_ca_bundle, tls_pinned_cert_sha256, auth_type, auth_config_encrypted, transform, filter, signing_profile, previous_secret, previous_secret_expires_at, replay_protection, verification_scheme FROM subscriptions WHERE id = ?
 `
 
 func (q *Queries) GetSubscription(ctx context.Context, id string) (Subscription, error) {
@@ -55,12 +110,128 @@ func (q *Queries) GetSubscription(ctx context.Context, id string) (Subscription,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.EventTypes,
+		&i.MaxAttempts,
+		&i.RetryBaseDelaySeconds,
+		&i.RetryMaxDelaySeconds,
+		&i.RetryMultiplier,
+		&i.RetryJitter,
+		&i.RetrySchedule,
+		&i.RateLimitPerSecond,
+		&i.RateLimitBurst,
+		&i.Ordered,
+		&i.BatchMaxSize,
+		&i.BatchMaxWaitSeconds,
+		&i.Headers,
+		&i.TlsClientCert,
+		&i.TlsClientKeyEncrypted,
+		&i.TlsCaBundle,
+		&i.TlsPinnedCertSha256,
+		&i.AuthType,
+		&i.AuthConfigEncrypted,
+		&i.Transform,
+		&i.Filter,
+		&i.SigningProfile,
+		&i.PreviousSecret,
+		&i.PreviousSecretExpiresAt,
+		&i.ReplayProtection,
+		&i.VerificationScheme,
 	)
 	return i, err
 }
 
+const listBatchingSubscriptions = `-- name: ListBatchingSubscriptions :many
+SELECT id, target_url, secret, created_at, updated_at, event_types, max_attempts, retry_base_delay_seconds, retry_max_delay_seconds, retry_multiplier, retry_jitter, retry_schedule, rate_limit_per_second, rate_limit_burst, ordered, batch_max_size, batch_max_wait_seconds, headers, tls_client_cert, tls_client_key_encrypted, tls_ca_bundle, tls_pinned_cert_sha256, auth_type, auth_config_encrypted, transform, filter, signing_profile, previous_secret, previous_secret_expires_at, replay_protection, verification_scheme FROM subscriptions WHERE batch_max_size > 1
+`
+
+func (q *Queries) ListBatchingSubscriptions(ctx context.Context) ([]Subscription, error) {
+	rows, err := q.db.QueryContext(ctx, listBatchingSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Subscription
+	for rows.Next() {
+		var i Subscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.TargetUrl,
+			&i.Secret,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EventTypes,
+			&i.MaxAttempts,
+			&i.RetryBaseDelaySeconds,
+			&i.RetryMaxDelaySeconds,
+			&i.RetryMultiplier,
+			&i.RetryJitter,
+			&i.RetrySchedule,
+			&i.RateLimitPerSecond,
+			&i.RateLimitBurst,
+			&i.Ordered,
+			&i.BatchMaxSize,
+			&i.BatchMaxWaitSeconds,
+			&i.Headers,
+			&i.TlsClientCert,
+			&i.TlsClientKeyEncrypted,
+			&i.TlsCaBundle,
+			&i.TlsPinnedCertSha256,
+			&i.AuthType,
+			&i.AuthConfigEncrypted,
+			&i.Transform,
+			&i.Filter,
+			&i.SigningProfile,
+			&i.PreviousSecret,
+			&i.PreviousSecretExpiresAt,
+			&i.ReplayProtection,
+			&i.VerificationScheme,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSecretRotations = `-- name: ListSecretRotations :many
+SELECT id, subscription_id, rotated_at, previous_secret_expires_at FROM secret_rotations WHERE subscription_id = ? ORDER BY rotated_at DESC, rowid DESC
+`
+
+func (q *Queries) ListSecretRotations(ctx context.Context, subscriptionID string) ([]SecretRotation, error) {
+	rows, err := q.db.QueryContext(ctx, listSecretRotations, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SecretRotation
+	for rows.Next() {
+		var i SecretRotation
+		if err := rows.Scan(
+			&i.ID,
+			&i.SubscriptionID,
+			&i.RotatedAt,
+			&i.PreviousSecretExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listSubscriptions = `-- name: ListSubscriptions :many
-SELECT id, target_url, secret, created_at, updated_at, event_types FROM subscriptions
+SELECT id, target_url, secret, created_at, updated_at, event_types, max_attempts, retry_base_delay_seconds, retry_max_delay_seconds, retry_multiplier, retry_jitter, retry_schedule, rate_limit_per_second, rate_limit_burst, ordered, batch_max_size, batch_max_wait_seconds, headers, tls_client_cert, tls_client_key_encrypted, tls_ca_bundle, tls_pinned_cert_sha256, auth_type, auth_config_encrypted, transform, filter, signing_profile, previous_secret, previous_secret_expires_at, replay_protection, verification_scheme FROM subscriptions
 `
 
 func (q *Queries) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
@@ -79,6 +250,31 @@ func (q *Queries) ListSubscriptions(ctx context.Context) ([]Subscription, error)
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.EventTypes,
+			&i.MaxAttempts,
+			&i.RetryBaseDelaySeconds,
+			&i.RetryMaxDelaySeconds,
+			&i.RetryMultiplier,
+			&i.RetryJitter,
+			&i.RetrySchedule,
+			&i.RateLimitPerSecond,
+			&i.RateLimitBurst,
+			&i.Ordered,
+			&i.BatchMaxSize,
+			&i.BatchMaxWaitSeconds,
+			&i.Headers,
+			&i.TlsClientCert,
+			&i.TlsClientKeyEncrypted,
+			&i.TlsCaBundle,
+			&i.TlsPinnedCertSha256,
+			&i.AuthType,
+			&i.AuthConfigEncrypted,
+			&i.Transform,
+			&i.Filter,
+			&i.SigningProfile,
+			&i.PreviousSecret,
+			&i.PreviousSecretExpiresAt,
+			&i.ReplayProtection,
+			&i.VerificationScheme,
 		); err != nil {
 			return nil, err
 		}
@@ -93,17 +289,56 @@ func (q *Queries) ListSubscriptions(ctx context.Context) ([]Subscription, error)
 	return items, nil
 }
 
+const rotateSubscriptionSecret = `-- name: RotateSubscriptionSecret :exec
+UPDATE subscriptions
+SET previous_secret = secret, previous_secret_expires_at = ?, secret = ?
+WHERE id = ?
+`
+
+type RotateSubscriptionSecretParams struct {
+	PreviousSecretExpiresAt sql.NullTime
+	Secret                  sql.NullString
+	ID                      string
+}
+
+func (q *Queries) RotateSubscriptionSecret(ctx context.Context, arg RotateSubscriptionSecretParams) error {
+	_, err := q.db.ExecContext(ctx, rotateSubscriptionSecret, arg.PreviousSecretExpiresAt, arg.Secret, arg.ID)
+	return err
+}
+
 const updateSubscription = `-- name: UpdateSubscription :exec
 UPDATE subscriptions
-SET target_url = ?, secret = ?, event_types = ?
+SET target_url = ?, secret = ?, event_types = ?,
+    max_attempts = ?, retry_base_delay_seconds = ?, retry_max_delay_seconds = ?,
+    retry_multiplier = ?, retry_jitter = ?, retry_schedule = ?,
+    rate_limit_per_second = ?, rate_limit_burst = ?, ordered = ?,
+    batch_max_size = ?, batch_max_wait_seconds = ?, headers = ?, transform = ?, filter = ?,
+    signing_profile = ?, replay_protection = ?, verification_scheme = ?
 WHERE id = ?
 `
 
 type UpdateSubscriptionParams struct {
-	TargetUrl  string
-	Secret     sql.NullString
-	EventTypes sql.NullString
-	ID         string
+	TargetUrl             string
+	Secret                sql.NullString
+	EventTypes            sql.NullString
+	MaxAttempts           sql.NullInt64
+	RetryBaseDelaySeconds sql.NullInt64
+	RetryMaxDelaySeconds  sql.NullInt64
+	RetryMultiplier       sql.NullFloat64
+	RetryJitter           sql.NullFloat64
+	RetrySchedule         sql.NullString
+	RateLimitPerSecond    sql.NullFloat64
+	RateLimitBurst        sql.NullInt64
+	Ordered               bool
+	BatchMaxSize          sql.NullInt64
+	BatchMaxWaitSeconds   sql.NullInt64
+	Headers               sql.NullString
+	Transform             sql.NullString
+	Filter                sql.NullString
+	SigningProfile        string
+	ReplayProtection      bool
+	VerificationScheme    string
+	ID                    string
 }
 
 func (q *Queries) UpdateSubscription(ctx context.Context, arg UpdateSubscriptionParams) error {
@@ -111,6 +346,65 @@ func (q *Queries) UpdateSubscription(ctx context.Context, arg UpdateSubscription
 		arg.TargetUrl,
 		arg.Secret,
 		arg.EventTypes,
+		arg.MaxAttempts,
+		arg.RetryBaseDelaySeconds,
+		arg.RetryMaxDelaySeconds,
+		arg.RetryMultiplier,
+		arg.RetryJitter,
+		arg.RetrySchedule,
+		arg.RateLimitPerSecond,
+		arg.RateLimitBurst,
+		arg.Ordered,
+		arg.BatchMaxSize,
+		arg.BatchMaxWaitSeconds,
+		arg.Headers,
+		arg.Transform,
+		arg.Filter,
+		arg.SigningProfile,
+		arg.ReplayProtection,
+		arg.VerificationScheme,
+		arg.ID,
+	)
+	return err
+}
+
+const updateSubscriptionAuth = `-- name: UpdateSubscriptionAuth :exec
+UPDATE subscriptions
+SET auth_type = ?, auth_config_encrypted = ?
+WHERE id = ?
+`
+
+type UpdateSubscriptionAuthParams struct {
+	AuthType            sql.NullString
+	AuthConfigEncrypted sql.NullString
+	ID                  string
+}
+
+func (q *Queries) UpdateSubscriptionAuth(ctx context.Context, arg UpdateSubscriptionAuthParams) error {
+	_, err := q.db.ExecContext(ctx, updateSubscriptionAuth, arg.AuthType, arg.AuthConfigEncrypted, arg.ID)
+	return err
+}
+
+const updateSubscriptionTLS = `-- name: UpdateSubscriptionTLS :exec
+UPDATE subscriptions
+SET tls_client_cert = ?, tls_client_key_encrypted = ?, tls_ca_bundle = ?, tls_pinned_cert_sha256 = ?
+WHERE id = ?
+`
+
+type UpdateSubscriptionTLSParams struct {
+	TlsClientCert         sql.NullString
+	TlsClientKeyEncrypted sql.NullString
+	TlsCaBundle           sql.NullString
+	TlsPinnedCertSha256   sql.NullString
+	ID                    string
+}
+
+func (q *Queries) UpdateSubscriptionTLS(ctx context.Context, arg UpdateSubscriptionTLSParams) error {
+	_, err := q.db.ExecContext(ctx, updateSubscriptionTLS,
+		arg.TlsClientCert,
+		arg.TlsClientKeyEncrypted,
+		arg.TlsCaBundle,
+		arg.TlsPinnedCertSha256,
 		arg.ID,
 	)
 	return err
@@ -0,0 +1,208 @@
+package delivery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+)
+
+// Outbound authentication types.
+const (
+    AuthBasic  = "basic"
+    AuthBearer = "bearer"
+    AuthOAuth2 = "oauth2"
+)
+
+const (
+    // tokenExpiryLeeway refreshes OAuth2 tokens a little before they expire.
+    tokenExpiryLeeway = 30 * time.Second
+    // defaultTokenLifetime is assumed when a token response has no expires_in.
+    defaultTokenLifetime = 5 * time.Minute
+)
+
+// AuthConfig holds the credentials a subscription's deliveries are sent
+// with. It is stored encrypted.
+type AuthConfig struct {
+    Type string `json:"type"`
+
+    // basic
+    Username string `json:"username,omitempty"`
+    Password string `json:"password,omitempty"`
+
+    // bearer
+    Token string `json:"token,omitempty"`
+
+    // oauth2 client credentials
+    TokenURL     string   `json:"token_url,omitempty"`
+    ClientID     string   `json:"client_id,omitempty"`
+    ClientSecret string   `json:"client_secret,omitempty"`
+    Scopes       []string `json:"scopes,omitempty"`
+}
+
+// Validate checks that the fields required by the auth type are present.
+func (c AuthConfig) Validate() error {
+    switch c.Type {
+    case AuthBasic:
+        if c.Username == "" {
+            return errors.New("basic auth requires a username")
+        }
+    case AuthBearer:
+        if c.Token == "" {
+            return errors.New("bearer auth requires a token")
+        }
+    case AuthOAuth2:
+        if c.TokenURL == "" || c.ClientID == "" || c.ClientSecret == "" {
+            return errors.New("oauth2 auth requires token_url, client_id and client_secret")
+        }
+        u, err := url.Parse(c.TokenURL)
+        if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            return errors.New("token_url must be an absolute http(s) URL")
+        }
+    default:
+        return fmt.Errorf("unknown auth type %q, expected basic, bearer or oauth2", c.Type)
+    }
+    return nil
+}
+
+// authFor decrypts the auth config of sub, or returns nil when it has none.
+func (w *Worker) authFor(sub database.Subscription) (*AuthConfig, error) {
+    if !sub.AuthConfigEncrypted.Valid || sub.AuthConfigEncrypted.String == "" {
+        return nil, nil
+    }
+    plaintext, err := w.Secrets.Decrypt(sub.AuthConfigEncrypted.String)
+    if err != nil {
+        return nil, err
+    }
+    var cfg AuthConfig
+    if err := json.Unmarshal([]byte(plaintext), &cfg); err != nil {
+        return nil, err
+    }
+    return &cfg, nil
+}
+
+// applyAuth adds the subscription's credentials to req, fetching an OAuth2
+// token first if none is cached. Tokens are fetched with the shared client:
+// the subscription's own TLS settings (pinned certificate, CA bundle, client
+// certificate) are meant for the target, not for its token endpoint.
+func (w *Worker) applyAuth(req *http.Request, sub database.Subscription, auth *AuthConfig) error {
+    if auth == nil {
+        return nil
+    }
+    switch auth.Type {
+    case AuthBasic:
+        req.SetBasicAuth(auth.Username, auth.Password)
+    case AuthBearer:
+        req.Header.Set("Authorization", "Bearer "+auth.Token)
+    case AuthOAuth2:
+        token, err := w.tokens.token(w.HTTPClient, sub.ID, authVersion(sub), *auth)
+        if err != nil {
+            return fmt.Errorf("fetching OAuth2 token: %w", err)
+        }
+        req.Header.Set("Authorization", "Bearer "+token)
+    }
+    return nil
+}
+
+// authVersion changes whenever the stored auth config does, so tokens
+// cached for old credentials are not reused.
+func authVersion(sub database.Subscription) string {
+    sum := sha256.Sum256([]byte(sub.AuthConfigEncrypted.String))
+    return hex.EncodeToString(sum[:])
+}
+
+// tokenCache holds OAuth2 access tokens per subscription.
+type tokenCache struct {
+    mu     sync.Mutex
+    tokens map[string]cachedToken
+}
+
+type cachedToken struct {
+    version     string
+    accessToken string
+    expiresAt   time.Time
+}
+
+// token returns a cached access token for key, fetching a new one when the
+// cached token is missing, stale or was issued for other credentials.
+func (c *tokenCache) token(client *http.Client, key, version string, cfg AuthConfig) (string, error) {
+    c.mu.Lock()
+    cached, ok := c.tokens[key]
+    c.mu.Unlock()
+    if ok && cached.version == version && time.Now().Add(tokenExpiryLeeway).Before(cached.expiresAt) {
+        return cached.accessToken, nil
+    }
+
+    fetched, err := fetchToken(client, cfg)
+    if err != nil {
+        return "", err
+    }
+    fetched.version = version
+
+    c.mu.Lock()
+    if c.tokens == nil {
+        c.tokens = make(map[string]cachedToken)
+    }
+    c.tokens[key] = fetched
+    c.mu.Unlock()
+    return fetched.accessToken, nil
+}
+
+// invalidate drops the cached token for key, e.g. after the target rejected
+// it with a 401.
+func (c *tokenCache) invalidate(key string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    delete(c.tokens, key)
+}
+
+// fetchToken runs the OAuth2 client credentials grant (RFC 6749 section 4.4)
+// against cfg.TokenURL.
+func fetchToken(client *http.Client, cfg AuthConfig) (cachedToken, error) {
+    form := url.Values{"grant_type": {"client_credentials"}}
+    if len(cfg.Scopes) > 0 {
+        form.Set("scope", strings.Join(cfg.Scopes, " "))
+    }
+    req, err := http.NewRequest(http.MethodPost, cfg.TokenURL, strings.NewReader(form.Encode()))
+    if err != nil {
+        return cachedToken{}, err
+    }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Accept", "application/json")
+    req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return cachedToken{}, err
+    }
+    defer resp.Body.Close()
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+    if resp.StatusCode != http.StatusOK {
+        return cachedToken{}, fmt.Errorf("token endpoint returned %s", resp.Status)
+    }
+
+    var parsed struct {
+        AccessToken string `json:"access_token"`
+        ExpiresIn   int64  `json:"expires_in"`
+    }
+    if err := json.Unmarshal(body, &parsed); err != nil {
+        return cachedToken{}, fmt.Errorf("invalid token response: %w", err)
+    }
+    if parsed.AccessToken == "" {
+        return cachedToken{}, errors.New("token response has no access_token")
+    }
+    lifetime := defaultTokenLifetime
+    if parsed.ExpiresIn > 0 {
+        lifetime = time.Duration(parsed.ExpiresIn) * time.Second
+    }
+    return cachedToken{accessToken: parsed.AccessToken, expiresAt: time.Now().Add(lifetime)}, nil
+}
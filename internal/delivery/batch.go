@@ -0,0 +1,196 @@
+package delivery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+)
+
+// batchEvent is one task inside a batched request body.
+type batchEvent struct {
+    ID      string          `json:"id"`
+    Payload json.RawMessage `json:"payload"`
+}
+
+// batchResponse is the optional body a target can return to report the
+// outcome of each event in a batch. Events it leaves out take the outcome
+// of the request as a whole.
+type batchResponse struct {
+    Results []struct {
+        ID     string `json:"id"`
+        Status int    `json:"status"`
+        Error  string `json:"error"`
+    } `json:"results"`
+}
+
+// dispatchBatches claims at most one ready batch per batching subscription,
+// up to free batches in total, and returns how many it handed to the pool.
+func (w *Worker) dispatchBatches(ctx context.Context, jobs chan<- deliveryJob, free int) int {
+    if free <= 0 {
+        return 0
+    }
+    subs, err := w.Queries.ListBatchingSubscriptions(ctx)
+    if err != nil {
+        log.Printf("error listing batching subscriptions: %v", err)
+        return 0
+    }
+
+    dispatched := 0
+    for _, sub := range subs {
+        if dispatched >= free {
+            break
+        }
+        now := time.Now()
+        maxWait := time.Duration(sub.BatchMaxWaitSeconds.Int64) * time.Second
+        claimed, err := w.Queries.ClaimDeliveryBatch(ctx, database.ClaimDeliveryBatchParams{
+            LeaseOwner:     sql.NullString{String: w.ID, Valid: true},
+            LeaseExpiresAt: sql.NullTime{Time: now.Add(w.LeaseDuration), Valid: true},
+            SubscriptionID: sub.ID,
+            Now:            sql.NullTime{Time: now, Valid: true},
+            BatchSize:      sub.BatchMaxSize.Int64,
+            ReadyBefore:    now.Add(-maxWait).UTC(),
+        })
+        if err != nil {
+            log.Printf("error claiming batch for subscription %s: %v", sub.ID, err)
+            continue
+        }
+        if len(claimed) == 0 {
+            continue
+        }
+        w.inFlight.Add(1)
+        jobs <- deliveryJob{tasks: claimed, batched: true}
+        dispatched++
+    }
+    return dispatched
+}
+
+// processBatch sends tasks, all of the same subscription, as one request and
+// records each task's outcome as its own attempt.
+func (w *Worker) processBatch(ctx context.Context, tasks []database.DeliveryTask) {
+    sub, err := w.subscriptionFor(ctx, tasks[0].SubscriptionID)
+    if err != nil {
+        for _, task := range tasks {
+            w.subscriptionUnavailable(ctx, task, err)
+        }
+        return
+    }
+
+    // A task whose payload cannot be transformed fails on its own; the rest
+    // are still sent together.
+    var ready []database.DeliveryTask
+    var payloads [][]byte
+    for _, task := range tasks {
+        payload, err := preparePayload(sub, task)
+        if err != nil {
+            w.recordAttempt(ctx, sub, task, transformFailure(err), "")
+            continue
+        }
+        ready = append(ready, task)
+        payloads = append(payloads, payload)
+    }
+    if len(ready) == 0 {
+        return
+    }
+    tasks = ready
+
+    host := TargetHost(sub.TargetUrl)
+    if allowed, retryAt := w.admit(ctx, sub, host); !allowed {
+        for _, task := range tasks {
+            w.deferTask(ctx, task, retryAt)
+        }
+        return
+    }
+
+    batchID := generateUUID()
+    results := w.deliverBatch(sub, batchID, tasks, payloads)
+    for i, task := range tasks {
+        w.recordAttempt(ctx, sub, task, results[i], batchID)
+    }
+}
+
+// deliverBatch posts tasks with their (transformed) payloads as a JSON array
+// and returns one result per task, in the same order.
+func (w *Worker) deliverBatch(sub database.Subscription, batchID string, tasks []database.DeliveryTask, payloads [][]byte) []deliveryResult {
+    events := make([]batchEvent, len(tasks))
+    for i, task := range tasks {
+        events[i] = batchEvent{ID: task.ID, Payload: json.RawMessage(payloads[i])}
+        if !json.Valid(events[i].Payload) {
+            // Non-JSON payloads are sent as JSON strings.
+            events[i].Payload, _ = json.Marshal(string(payloads[i]))
+        }
+    }
+
+    results := make([]deliveryResult, len(tasks))
+    body, err := json.Marshal(events)
+    if err != nil {
+        for i := range results {
+            results[i] = deliveryResult{Status: "failed_attempt", ErrMsg: err.Error(), Classification: classificationRetryable}
+        }
+        return results
+    }
+
+    overall := w.deliverWebhook(sub, batchHeaderVars(batchID, tasks), body)
+    w.recordBreaker(TargetHost(sub.TargetUrl), overall)
+    for i := range results {
+        results[i] = overall
+    }
+    if overall.Classification != classificationSuccess {
+        return results
+    }
+
+    var parsed batchResponse
+    if len(overall.ResponseBody) == 0 || json.Unmarshal(overall.ResponseBody, &parsed) != nil {
+        return results
+    }
+    index := make(map[string]int, len(tasks))
+    for i, task := range tasks {
+        index[task.ID] = i
+    }
+    for _, r := range parsed.Results {
+        i, ok := index[r.ID]
+        if !ok || r.Status == 0 {
+            continue
+        }
+        result := deliveryResult{
+            ReachedTarget:   true,
+            HTTPStatus:      r.Status,
+            Classification:  classifyResponse(r.Status, w.PermanentStatusCodes),
+            ResponseBody:    overall.ResponseBody,
+            ResponseHeaders: overall.ResponseHeaders,
+            Latency:         overall.Latency,
+            Timings:         overall.Timings,
+        }
+        if result.Classification == classificationSuccess {
+            result.Status = "success"
+        } else {
+            result.Status = "failed_attempt"
+            result.ErrMsg = r.Error
+            if result.ErrMsg == "" {
+                result.ErrMsg = http.StatusText(r.Status)
+            }
+        }
+        results[i] = result
+    }
+    return results
+}
+
+// batchHeaderVars describes a batch to header templates: TaskID is the batch
+// ID, EventType is set only when every task shares it, and Attempt is the
+// highest attempt number in the batch.
+func batchHeaderVars(batchID string, tasks []database.DeliveryTask) headerVars {
+    vars := headerVars{TaskID: batchID, EventType: tasks[0].EventType.String}
+    for _, task := range tasks {
+        if task.EventType.String != vars.EventType {
+            vars.EventType = ""
+        }
+        if task.AttemptCount+1 > vars.Attempt {
+            vars.Attempt = task.AttemptCount + 1
+        }
+    }
+    return vars
+}
@@ -0,0 +1,160 @@
+package delivery
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states.
+const (
+    BreakerClosed   = "closed"
+    BreakerOpen     = "open"
+    BreakerHalfOpen = "half_open"
+)
+
+// breakerProbeWait is how long tasks are deferred while a half-open probe is
+// still in flight.
+const breakerProbeWait = 5 * time.Second
+
+// CircuitBreakers tracks one circuit breaker per target host. After
+// FailureThreshold consecutive failures the host's circuit opens and
+// deliveries to it are deferred for OpenDuration. The first delivery after
+// that is let through as a probe: success closes the circuit, failure opens
+// it again.
+type CircuitBreakers struct {
+    FailureThreshold int
+    OpenDuration     time.Duration
+
+    mu       sync.Mutex
+    breakers map[string]*hostBreaker
+}
+
+type hostBreaker struct {
+    state    string
+    failures int
+    openedAt time.Time
+    probing  bool
+}
+
+// BreakerStatus is a point-in-time view of one host's circuit.
+type BreakerStatus struct {
+    Host     string    `json:"host"`
+    State    string    `json:"state"`
+    Failures int       `json:"consecutive_failures"`
+    OpenedAt time.Time `json:"opened_at,omitzero"`
+    RetryAt  time.Time `json:"retry_at,omitzero"`
+}
+
+func NewCircuitBreakers(failureThreshold int, openDuration time.Duration) *CircuitBreakers {
+    return &CircuitBreakers{
+        FailureThreshold: failureThreshold,
+        OpenDuration:     openDuration,
+        breakers:         make(map[string]*hostBreaker),
+    }
+}
+
+// Allow reports whether a delivery to host may be sent now. When it may not,
+// the returned time is when the caller should try again.
+func (b *CircuitBreakers) Allow(host string, now time.Time) (bool, time.Time) {
+    if b == nil || host == "" {
+        return true, time.Time{}
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    hb, ok := b.breakers[host]
+    if !ok {
+        return true, time.Time{}
+    }
+    switch hb.state {
+    case BreakerOpen:
+        retryAt := hb.openedAt.Add(b.OpenDuration)
+        if now.Before(retryAt) {
+            return false, retryAt
+        }
+        hb.state = BreakerHalfOpen
+        hb.probing = true
+        return true, time.Time{}
+    case BreakerHalfOpen:
+        if hb.probing {
+            return false, now.Add(breakerProbeWait)
+        }
+        hb.probing = true
+        return true, time.Time{}
+    default:
+        return true, time.Time{}
+    }
+}
+
+// Record feeds the result of a delivery to host back into its breaker.
+func (b *CircuitBreakers) Record(host string, success bool, now time.Time) {
+    if b == nil || host == "" {
+        return
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    hb, ok := b.breakers[host]
+    if !ok {
+        if success {
+            return
+        }
+        hb = &hostBreaker{state: BreakerClosed}
+        b.breakers[host] = hb
+    }
+
+    if success {
+        delete(b.breakers, host)
+        return
+    }
+    hb.failures++
+    hb.probing = false
+    if hb.state == BreakerHalfOpen || hb.failures >= b.FailureThreshold {
+        hb.state = BreakerOpen
+        hb.openedAt = now
+    }
+}
+
+// State returns the current state of host's circuit.
+func (b *CircuitBreakers) State(host string) string {
+    if b == nil {
+        return BreakerClosed
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if hb, ok := b.breakers[host]; ok {
+        return hb.state
+    }
+    return BreakerClosed
+}
+
+// Snapshot lists every host that has recorded failures, sorted by host.
+func (b *CircuitBreakers) Snapshot() []BreakerStatus {
+    statuses := []BreakerStatus{}
+    if b == nil {
+        return statuses
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    for host, hb := range b.breakers {
+        status := BreakerStatus{Host: host, State: hb.state, Failures: hb.failures}
+        if hb.state != BreakerClosed {
+            status.OpenedAt = hb.openedAt
+            status.RetryAt = hb.openedAt.Add(b.OpenDuration)
+        }
+        statuses = append(statuses, status)
+    }
+    sort.Slice(statuses, func(i, j int) bool { return statuses[i].Host < statuses[j].Host })
+    return statuses
+}
+
+// TargetHost returns the host (and port, if any) that a breaker is keyed by.
+func TargetHost(targetURL string) string {
+    u, err := url.Parse(targetURL)
+    if err != nil {
+        return ""
+    }
+    return u.Host
+}
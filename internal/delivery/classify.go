@@ -0,0 +1,90 @@
+package delivery
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Classifications recorded on each delivery log.
+const (
+    classificationSuccess   = "success"
+    classificationRetryable = "retryable"
+    classificationThrottled = "throttled"
+    classificationPermanent = "permanent"
+)
+
+var defaultPermanentStatusCodes = []int{
+    http.StatusBadRequest,
+    http.StatusUnauthorized,
+    http.StatusForbidden,
+    http.StatusNotFound,
+    http.StatusGone,
+}
+
+// maxRetryAfter bounds how far a target can push back a retry via Retry-After.
+const maxRetryAfter = 24 * time.Hour
+
+// classifyResponse decides what a response means for the task: delivered,
+// worth retrying, retry after the target's requested delay, or never going
+// to succeed. Transport errors (statusCode 0) are always retryable.
+func classifyResponse(statusCode int, permanent map[int]bool) string {
+    switch {
+    case statusCode >= 200 && statusCode < 300:
+        return classificationSuccess
+    case statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable:
+        return classificationThrottled
+    case permanent[statusCode]:
+        return classificationPermanent
+    default:
+        return classificationRetryable
+    }
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date. It returns 0 when the header is missing or unusable.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return 0
+    }
+    var d time.Duration
+    if secs, err := strconv.Atoi(value); err == nil {
+        d = time.Duration(secs) * time.Second
+    } else if t, err := http.ParseTime(value); err == nil {
+        d = t.Sub(now)
+    }
+    if d < 0 {
+        return 0
+    }
+    if d > maxRetryAfter {
+        return maxRetryAfter
+    }
+    return d
+}
+
+// permanentStatusCodesFromEnv reads DELIVERY_PERMANENT_STATUS_CODES, a
+// comma-separated list of HTTP status codes that are dead-lettered without
+// retrying.
+func permanentStatusCodesFromEnv() map[int]bool {
+    codes := map[int]bool{}
+    v := os.Getenv("DELIVERY_PERMANENT_STATUS_CODES")
+    if v == "" {
+        for _, code := range defaultPermanentStatusCodes {
+            codes[code] = true
+        }
+        return codes
+    }
+    for _, part := range strings.Split(v, ",") {
+        code, err := strconv.Atoi(strings.TrimSpace(part))
+        if err != nil || code < 100 || code > 599 {
+            log.Printf("Warning: ignoring invalid status code '%s' in DELIVERY_PERMANENT_STATUS_CODES", part)
+            continue
+        }
+        codes[code] = true
+    }
+    return codes
+}
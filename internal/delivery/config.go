@@ -0,0 +1,55 @@
+package delivery
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envDuration reads a duration such as "30s" or "2m" from the environment,
+// falling back to def when the variable is unset or malformed.
+func envDuration(key string, def time.Duration) time.Duration {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    d, err := time.ParseDuration(v)
+    if err != nil || d <= 0 {
+        log.Printf("Warning: invalid %s '%s', using default %s", key, v, def)
+        return def
+    }
+    return d
+}
+
+// envInt reads a positive integer from the environment, falling back to def
+// when the variable is unset or malformed.
+func envInt(key string, def int) int {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil || n <= 0 {
+        log.Printf("Warning: invalid %s '%s', using default %d", key, v, def)
+        return def
+    }
+    return n
+}
+
+// envList reads a comma-separated list from the environment, falling back to
+// def when the variable is unset.
+func envList(key string, def []string) []string {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    var list []string
+    for _, item := range strings.Split(v, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            list = append(list, item)
+        }
+    }
+    return list
+}
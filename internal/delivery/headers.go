@@ -0,0 +1,93 @@
+package delivery
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// headerVars is the data available to templated header values, e.g.
+// "{{.EventType}}" or "attempt-{{.Attempt}}".
+type headerVars struct {
+    EventType string
+    TaskID    string
+    Attempt   int64
+    Timestamp int64 // Unix seconds, same as X-Webhook-Timestamp
+}
+
+// reservedHeaders are set by the worker itself and cannot be overridden.
+var reservedHeaders = map[string]bool{
+    "Content-Length":               true,
+    "Host":                         true,
+    "X-Webhook-Id":                 true,
+    "X-Webhook-Timestamp":          true,
+    "X-Hub-Signature-256":          true,
+    "X-Hub-Signature-256-Previous": true,
+    "Webhook-Id":                   true,
+    "Webhook-Timestamp":            true,
+    "Webhook-Signature":            true,
+}
+
+// ValidateHeaders checks a subscription's custom headers: names must be
+// usable HTTP header names that the worker does not set itself, and values
+// must be valid templates.
+func ValidateHeaders(headers map[string]string) error {
+    for name, value := range headers {
+        if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+            return fmt.Errorf("invalid header name %q", name)
+        }
+        if reservedHeaders[http.CanonicalHeaderKey(name)] {
+            return fmt.Errorf("header %q is set by the service and cannot be overridden", name)
+        }
+        if strings.ContainsAny(value, "\r\n") {
+            return fmt.Errorf("value of header %q must not contain line breaks", name)
+        }
+        if _, err := template.New(name).Parse(value); err != nil {
+            return fmt.Errorf("invalid template in header %q: %w", name, err)
+        }
+    }
+    return nil
+}
+
+// applyHeaders renders the subscription's custom headers onto req. A header
+// whose template fails to render is skipped rather than failing the delivery.
+func applyHeaders(req *http.Request, raw sql.NullString, vars headerVars) {
+    if !raw.Valid || raw.String == "" {
+        return
+    }
+    var headers map[string]string
+    if err := json.Unmarshal([]byte(raw.String), &headers); err != nil {
+        log.Printf("ignoring malformed custom headers: %v", err)
+        return
+    }
+    for name, value := range headers {
+        if reservedHeaders[http.CanonicalHeaderKey(name)] {
+            continue
+        }
+        rendered, err := renderHeader(name, value, vars)
+        if err != nil || strings.ContainsAny(rendered, "\r\n") {
+            log.Printf("skipping custom header %s: %v", name, err)
+            continue
+        }
+        req.Header.Set(name, rendered)
+    }
+}
+
+func renderHeader(name, value string, vars headerVars) (string, error) {
+    if !strings.Contains(value, "{{") {
+        return value, nil
+    }
+    tmpl, err := template.New(name).Option("missingkey=error").Parse(value)
+    if err != nil {
+        return "", err
+    }
+    var b strings.Builder
+    if err := tmpl.Execute(&b, vars); err != nil {
+        return "", err
+    }
+    return b.String(), nil
+}
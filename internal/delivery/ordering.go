@@ -0,0 +1,21 @@
+package delivery
+
+import (
+	"database/sql"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+)
+
+// OrderingKeyHeader lets a sender put an event into a named FIFO stream.
+const OrderingKeyHeader = "X-Ordering-Key"
+
+// OrderingKey returns the stream a new task for sub belongs to. Tasks sharing
+// a stream are delivered strictly one after another. An ordered subscription
+// puts every task without an explicit key into its default ("") stream;
+// otherwise only tasks sent with a key are ordered.
+func OrderingKey(sub database.Subscription, key string) sql.NullString {
+    if key == "" && !sub.Ordered {
+        return sql.NullString{}
+    }
+    return sql.NullString{String: key, Valid: true}
+}
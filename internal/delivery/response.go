@@ -0,0 +1,50 @@
+package delivery
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+var defaultCapturedResponseHeaders = []string{"Content-Type", "Retry-After", "X-Request-Id"}
+
+// captureHeaders copies the named headers out of a response.
+func captureHeaders(header http.Header, names []string) http.Header {
+    captured := http.Header{}
+    for _, name := range names {
+        if values := header.Values(name); len(values) > 0 {
+            captured[http.CanonicalHeaderKey(name)] = values
+        }
+    }
+    return captured
+}
+
+// encodeHeaders stores captured headers as a JSON object of name to
+// comma-joined values.
+func encodeHeaders(header http.Header) sql.NullString {
+    if len(header) == 0 {
+        return sql.NullString{}
+    }
+    flat := make(map[string]string, len(header))
+    for name, values := range header {
+        flat[name] = strings.Join(values, ", ")
+    }
+    encoded, err := json.Marshal(flat)
+    if err != nil {
+        return sql.NullString{}
+    }
+    return sql.NullString{String: string(encoded), Valid: true}
+}
+
+// truncateBody keeps at most limit bytes of a response body, dropping bytes
+// that are not valid UTF-8 (such as a character split by the cut).
+func truncateBody(body []byte, limit int) sql.NullString {
+    if len(body) == 0 {
+        return sql.NullString{}
+    }
+    if len(body) > limit {
+        body = body[:limit]
+    }
+    return sql.NullString{String: strings.ToValidUTF8(string(body), ""), Valid: true}
+}
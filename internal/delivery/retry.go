@@ -0,0 +1,129 @@
+package delivery
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+)
+
+// RetryPolicy decides how often a task is attempted and how long the worker
+// waits before the next attempt.
+type RetryPolicy struct {
+    MaxAttempts int
+    // Schedule, when set, lists the delay after each failed attempt. The
+    // last entry is reused once the schedule runs out.
+    Schedule   []time.Duration
+    BaseDelay  time.Duration
+    MaxDelay   time.Duration
+    Multiplier float64
+    // Jitter randomises each delay by up to this fraction in either direction.
+    Jitter float64
+}
+
+const (
+    defaultRetryMultiplier = 2.0
+    defaultRetryMaxDelay   = 24 * time.Hour
+)
+
+var defaultRetryPolicy = RetryPolicy{
+    MaxAttempts: 5,
+    Schedule: []time.Duration{
+        10 * time.Second,
+        30 * time.Second,
+        1 * time.Minute,
+        5 * time.Minute,
+        15 * time.Minute,
+    },
+}
+
+// retryPolicyFor builds the policy configured on sub, using the service
+// defaults for anything left unset.
+func retryPolicyFor(sub database.Subscription) RetryPolicy {
+    policy := defaultRetryPolicy
+    if sub.MaxAttempts.Valid && sub.MaxAttempts.Int64 > 0 {
+        policy.MaxAttempts = int(sub.MaxAttempts.Int64)
+    }
+    if sub.RetryJitter.Valid {
+        policy.Jitter = sub.RetryJitter.Float64
+    }
+
+    if sub.RetrySchedule.Valid && sub.RetrySchedule.String != "" {
+        schedule, err := ParseRetrySchedule(sub.RetrySchedule.String)
+        if err == nil {
+            policy.Schedule = schedule
+            return policy
+        }
+    }
+
+    if sub.RetryBaseDelaySeconds.Valid && sub.RetryBaseDelaySeconds.Int64 > 0 {
+        policy.Schedule = nil
+        policy.BaseDelay = time.Duration(sub.RetryBaseDelaySeconds.Int64) * time.Second
+        policy.MaxDelay = defaultRetryMaxDelay
+        policy.Multiplier = defaultRetryMultiplier
+        if sub.RetryMaxDelaySeconds.Valid && sub.RetryMaxDelaySeconds.Int64 > 0 {
+            policy.MaxDelay = time.Duration(sub.RetryMaxDelaySeconds.Int64) * time.Second
+        }
+        if sub.RetryMultiplier.Valid && sub.RetryMultiplier.Float64 >= 1 {
+            policy.Multiplier = sub.RetryMultiplier.Float64
+        }
+    }
+    return policy
+}
+
+// Backoff returns how long to wait after the given (1-based) failed attempt.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+    var delay time.Duration
+    if len(p.Schedule) > 0 {
+        idx := attempt - 1
+        if idx >= len(p.Schedule) {
+            idx = len(p.Schedule) - 1
+        }
+        if idx < 0 {
+            idx = 0
+        }
+        delay = p.Schedule[idx]
+    } else {
+        scaled := float64(p.BaseDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+        if scaled > float64(p.MaxDelay) {
+            scaled = float64(p.MaxDelay)
+        }
+        delay = time.Duration(scaled)
+    }
+
+    if p.Jitter > 0 {
+        spread := float64(delay) * p.Jitter
+        delay += time.Duration((rand.Float64()*2 - 1) * spread)
+    }
+    if delay < 0 {
+        delay = 0
+    }
+    return delay
+}
+
+// ParseRetrySchedule parses a comma-separated list of Go durations such as
+// "10s,1m,1h".
+func ParseRetrySchedule(s string) ([]time.Duration, error) {
+    var schedule []time.Duration
+    for _, part := range strings.Split(s, ",") {
+        part = strings.TrimSpace(part)
+        if part == "" {
+            continue
+        }
+        d, err := time.ParseDuration(part)
+        if err != nil {
+            return nil, fmt.Errorf("invalid retry delay %q: %w", part, err)
+        }
+        if d < 0 {
+            return nil, fmt.Errorf("retry delay %q must not be negative", part)
+        }
+        schedule = append(schedule, d)
+    }
+    if len(schedule) == 0 {
+        return nil, fmt.Errorf("retry schedule is empty")
+    }
+    return schedule, nil
+}
@@ -2,6 +2,7 @@ package delivery
 
 import (
 	"context"
+	"database/sql"
 	"log"
 	"time"
 
@@ -42,11 +43,27 @@ func (w *ScheduledWorker) processDueScheduledWebhooks(ctx context.Context) {
         log.Printf("Scheduled Worker: Found %d due webhooks.", len(tasks))
     }
     for _, task := range tasks {
+        var orderingKey sql.NullString
+        if sub, err := w.Queries.GetSubscription(ctx, task.SubscriptionID); err == nil {
+            orderingKey = OrderingKey(sub, "")
+        }
+        eventID := uuid.New().String()
+        err := w.Queries.CreateEvent(ctx, database.CreateEventParams{
+            ID:      eventID,
+            Payload: task.Payload,
+            Source:  "scheduled",
+        })
+        if err != nil {
+            log.Printf("Scheduled Worker: Error storing event for %s: %v", task.ID, err)
+            continue
+        }
         deliveryTaskID := uuid.New().String()
-        err := w.Queries.CreateDeliveryTask(ctx, database.CreateDeliveryTaskParams{
+        err = w.Queries.CreateDeliveryTask(ctx, database.CreateDeliveryTaskParams{
             ID:             deliveryTaskID,
             SubscriptionID: task.SubscriptionID,
             Payload:        task.Payload,
+            OrderingKey:    orderingKey,
+            EventID:        sql.NullString{String: eventID, Valid: true},
         })
         if err != nil {
             _ = w.Queries.UpdateScheduledWebhookStatus(ctx, database.UpdateScheduledWebhookStatusParams{
@@ -0,0 +1,194 @@
+package delivery
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+)
+
+// Signing profiles a subscription can choose.
+const (
+    // SigningProfileHMACSHA256 signs the body into X-Hub-Signature-256, the
+    // default.
+    SigningProfileHMACSHA256 = "hmac-sha256"
+    // SigningProfileStandard follows the Standard Webhooks spec
+    // (https://www.standardwebhooks.com): webhook-id, webhook-timestamp and
+    // webhook-signature headers and a {type, timestamp, data} envelope.
+    SigningProfileStandard = "standard-webhooks"
+)
+
+const signatureSchemeNone = "none"
+
+// standardSecretPrefix marks Standard Webhooks secrets; the rest is the
+// base64-encoded key.
+const standardSecretPrefix = "whsec_"
+
+// ValidateSigningProfile checks a subscription's signing profile and that
+// its secret suits it. An empty profile means the default.
+func ValidateSigningProfile(profile, secret string) error {
+    switch profile {
+    case "", SigningProfileHMACSHA256:
+        return nil
+    case SigningProfileStandard:
+        if secret == "" {
+            return errors.New("signing_profile standard-webhooks requires a secret")
+        }
+        _, err := standardKey(secret)
+        return err
+    }
+    return fmt.Errorf("unknown signing_profile %q, expected %s or %s", profile, SigningProfileHMACSHA256, SigningProfileStandard)
+}
+
+// NewStandardSecret returns a random whsec_ secret for the Standard
+// Webhooks profile.
+func NewStandardSecret() (string, error) {
+    key := make([]byte, 32)
+    if _, err := rand.Read(key); err != nil {
+        return "", err
+    }
+    return standardSecretPrefix + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// NewSecret returns a random secret suited to profile.
+func NewSecret(profile string) (string, error) {
+    if profile == SigningProfileStandard {
+        return NewStandardSecret()
+    }
+    key := make([]byte, 32)
+    if _, err := rand.Read(key); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(key), nil
+}
+
+// ActiveSecrets returns the secrets sub's signatures are made and checked
+// with at now: the current one first, then the one it replaced while the
+// rotation grace period lasts.
+func ActiveSecrets(sub database.Subscription, now time.Time) []string {
+    var secrets []string
+    if sub.Secret.Valid && sub.Secret.String != "" {
+        secrets = append(secrets, sub.Secret.String)
+    }
+    if sub.PreviousSecret.Valid && sub.PreviousSecret.String != "" &&
+        sub.PreviousSecretExpiresAt.Valid && now.Before(sub.PreviousSecretExpiresAt.Time) {
+        secrets = append(secrets, sub.PreviousSecret.String)
+    }
+    return secrets
+}
+
+// standardKey decodes the HMAC key of a whsec_ secret.
+func standardKey(secret string) ([]byte, error) {
+    encoded, ok := strings.CutPrefix(secret, standardSecretPrefix)
+    if !ok {
+        return nil, errors.New("standard-webhooks secrets must start with whsec_")
+    }
+    key, err := base64.StdEncoding.DecodeString(encoded)
+    if err != nil || len(key) < 24 || len(key) > 64 {
+        return nil, errors.New("standard-webhooks secret must be whsec_ followed by 24 to 64 base64-encoded bytes")
+    }
+    return key, nil
+}
+
+// previousSignatureHeader carries the signature made with the previous
+// secret while a rotation's grace period runs.
+const previousSignatureHeader = "X-Hub-Signature-256-Previous"
+
+// signatureScheme reports which scheme signRequest applies for sub.
+func signatureScheme(sub database.Subscription) string {
+    if !sub.Secret.Valid || sub.Secret.String == "" {
+        return signatureSchemeNone
+    }
+    if sub.SigningProfile == SigningProfileStandard {
+        return SigningProfileStandard
+    }
+    return SigningProfileHMACSHA256
+}
+
+// signRequest stamps an outbound delivery with its ID and send time and, when
+// the subscription has a secret, signs it according to its profile. The
+// hmac-sha256 profile adds an X-Hub-Signature-256 header computed the same
+// way IngestWebhook verifies inbound requests. deliveryID must stay the same
+// across retries so receivers can deduplicate on it.
+//
+// During a secret rotation the request is also signed with the previous
+// secret. The hmac-sha256 profile keeps X-Hub-Signature-256 for the current
+// secret and puts the previous one's in X-Hub-Signature-256-Previous, so
+// each header has exactly one value; webhook-signature lists both,
+// space-separated, as the Standard Webhooks spec allows.
+func signRequest(req *http.Request, sub database.Subscription, deliveryID string, payload []byte, now time.Time) error {
+    timestamp := strconv.FormatInt(now.Unix(), 10)
+    req.Header.Set("X-Webhook-ID", deliveryID)
+    req.Header.Set("X-Webhook-Timestamp", timestamp)
+
+    secrets := ActiveSecrets(sub, now)
+    switch signatureScheme(sub) {
+    case SigningProfileHMACSHA256:
+        req.Header.Set("X-Hub-Signature-256", computeSignature(payload, secrets[0]))
+        if len(secrets) > 1 {
+            req.Header.Set(previousSignatureHeader, computeSignature(payload, secrets[1]))
+        }
+    case SigningProfileStandard:
+        var signatures []string
+        for i, secret := range secrets {
+            signature, err := computeStandardSignature(secret, deliveryID, timestamp, payload)
+            if err != nil {
+                if i == 0 {
+                    return err
+                }
+                // A previous secret from before the switch to this
+                // profile cannot sign; the current one still does.
+                continue
+            }
+            signatures = append(signatures, signature)
+        }
+        req.Header.Set("webhook-id", deliveryID)
+        req.Header.Set("webhook-timestamp", timestamp)
+        req.Header.Set("webhook-signature", strings.Join(signatures, " "))
+    }
+    return nil
+}
+
+func computeSignature(payload []byte, secret string) string {
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write(payload)
+    return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// computeStandardSignature returns the webhook-signature value: "v1," and the
+// base64 HMAC-SHA256 of "id.timestamp.payload".
+func computeStandardSignature(secret, id, timestamp string, payload []byte) (string, error) {
+    key, err := standardKey(secret)
+    if err != nil {
+        return "", err
+    }
+    mac := hmac.New(sha256.New, key)
+    mac.Write([]byte(id + "." + timestamp + "."))
+    mac.Write(payload)
+    return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// standardEnvelope wraps payload as a Standard Webhooks event, with the time
+// the event was received so the body is identical on every retry. Non-JSON
+// payloads become a JSON string.
+func standardEnvelope(task database.DeliveryTask, payload []byte) ([]byte, error) {
+    data := json.RawMessage(payload)
+    if !json.Valid(data) {
+        data, _ = json.Marshal(string(payload))
+    }
+    return json.Marshal(struct {
+        Type      string          `json:"type"`
+        Timestamp time.Time       `json:"timestamp"`
+        Data      json.RawMessage `json:"data"`
+    }{task.EventType.String, task.CreatedAt.UTC(), data})
+}
@@ -0,0 +1,62 @@
+package delivery
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+)
+
+func TestSignRequestDuringRotationSendsOneValuePerHeader(t *testing.T) {
+    now := time.Now()
+    payload := []byte(`{"order_id":"1234"}`)
+    sub := database.Subscription{
+        ID:                      "sub",
+        Secret:                  sql.NullString{String: "new-secret", Valid: true},
+        PreviousSecret:          sql.NullString{String: "old-secret", Valid: true},
+        PreviousSecretExpiresAt: sql.NullTime{Time: now.Add(time.Hour), Valid: true},
+        SigningProfile:          SigningProfileHMACSHA256,
+    }
+    req, err := http.NewRequest(http.MethodPost, "https://example.com/hook", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := signRequest(req, sub, "task", payload, now); err != nil {
+        t.Fatal(err)
+    }
+
+    for name, values := range req.Header {
+        if len(values) != 1 {
+            t.Errorf("header %s has %d values %q, want exactly one", name, len(values), values)
+        }
+    }
+    if got, want := req.Header.Get("X-Hub-Signature-256"), computeSignature(payload, "new-secret"); got != want {
+        t.Errorf("X-Hub-Signature-256 = %q, want the current secret's signature %q", got, want)
+    }
+    if got, want := req.Header.Get(previousSignatureHeader), computeSignature(payload, "old-secret"); got != want {
+        t.Errorf("%s = %q, want the previous secret's signature %q", previousSignatureHeader, got, want)
+    }
+}
+
+func TestSignRequestAfterGracePeriodOmitsPreviousSignature(t *testing.T) {
+    now := time.Now()
+    sub := database.Subscription{
+        Secret:                  sql.NullString{String: "new-secret", Valid: true},
+        PreviousSecret:          sql.NullString{String: "old-secret", Valid: true},
+        PreviousSecretExpiresAt: sql.NullTime{Time: now.Add(-time.Minute), Valid: true},
+        SigningProfile:          SigningProfileHMACSHA256,
+    }
+    req, _ := http.NewRequest(http.MethodPost, "https://example.com/hook", nil)
+    if err := signRequest(req, sub, "task", []byte(`{}`), now); err != nil {
+        t.Fatal(err)
+    }
+    if v, ok := req.Header[previousSignatureHeader]; ok {
+        t.Errorf("%s = %q after the grace period, want it absent", previousSignatureHeader, v)
+    }
+    if !strings.HasPrefix(req.Header.Get("X-Hub-Signature-256"), "sha256=") {
+        t.Errorf("X-Hub-Signature-256 = %q, want a sha256= signature", req.Header.Get("X-Hub-Signature-256"))
+    }
+}
@@ -0,0 +1,165 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedTarget is returned when a target resolves to an address that
+// deliveries may not be sent to.
+var ErrBlockedTarget = errors.New("target address is not allowed")
+
+// blockedPrefixes are special-purpose ranges that IsGlobalUnicast and
+// IsPrivate do not exclude.
+var blockedPrefixes = []netip.Prefix{
+    netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+    netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+    netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+    netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+    netip.MustParsePrefix("240.0.0.0/4"),     // reserved, incl. broadcast
+    netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, can reach IPv4 internals
+    netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+}
+
+// TargetPolicy decides which hosts deliveries may be sent to. Loopback,
+// private, link-local and other non-public addresses are refused unless
+// they are on the allowlist.
+type TargetPolicy struct {
+    allowHosts    map[string]bool
+    allowPrefixes []netip.Prefix
+}
+
+// NewTargetPolicy builds a policy from allowlist entries, each a hostname,
+// an IP address or a CIDR range.
+func NewTargetPolicy(allowlist []string) (*TargetPolicy, error) {
+    p := &TargetPolicy{allowHosts: map[string]bool{}}
+    for _, entry := range allowlist {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+        if strings.Contains(entry, "/") {
+            prefix, err := netip.ParsePrefix(entry)
+            if err != nil {
+                return nil, fmt.Errorf("invalid CIDR %q", entry)
+            }
+            p.allowPrefixes = append(p.allowPrefixes, prefix.Masked())
+            continue
+        }
+        if addr, err := netip.ParseAddr(entry); err == nil {
+            addr = addr.Unmap()
+            p.allowPrefixes = append(p.allowPrefixes, netip.PrefixFrom(addr, addr.BitLen()))
+            continue
+        }
+        p.allowHosts[strings.ToLower(strings.TrimSuffix(entry, "."))] = true
+    }
+    return p, nil
+}
+
+// TargetPolicyFromEnv builds the policy from SSRF_ALLOWLIST, a
+// comma-separated list of hostnames, IPs and CIDR ranges.
+func TargetPolicyFromEnv() (*TargetPolicy, error) {
+    return NewTargetPolicy(strings.Split(os.Getenv("SSRF_ALLOWLIST"), ","))
+}
+
+// hostAllowed reports whether host is allowlisted by name.
+func (p *TargetPolicy) hostAllowed(host string) bool {
+    return p.allowHosts[strings.ToLower(strings.TrimSuffix(host, "."))]
+}
+
+// addrAllowed reports whether deliveries may connect to addr.
+func (p *TargetPolicy) addrAllowed(addr netip.Addr) bool {
+    addr = addr.Unmap()
+    for _, prefix := range p.allowPrefixes {
+        if prefix.Contains(addr) {
+            return true
+        }
+    }
+    return isPublic(addr)
+}
+
+// isPublic reports whether addr is a globally routable unicast address.
+func isPublic(addr netip.Addr) bool {
+    if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+        return false
+    }
+    for _, prefix := range blockedPrefixes {
+        if prefix.Contains(addr) {
+            return false
+        }
+    }
+    return true
+}
+
+// ValidateURL checks a target URL when a subscription is saved: it must be
+// an absolute http(s) URL whose host is allowlisted or resolves only to
+// public addresses. Hosts that do not resolve yet are accepted; the dial
+// guard checks them again on every delivery. A nil policy allows everything.
+func (p *TargetPolicy) ValidateURL(raw string) error {
+    u, err := url.Parse(raw)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        return errors.New("target_url must be an absolute http(s) URL")
+    }
+    if p == nil {
+        return nil
+    }
+    host := u.Hostname()
+    if p.hostAllowed(host) {
+        return nil
+    }
+    if addr, err := netip.ParseAddr(host); err == nil {
+        if !p.addrAllowed(addr) {
+            return fmt.Errorf("%w: %s", ErrBlockedTarget, addr)
+        }
+        return nil
+    }
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+    if err != nil {
+        return nil
+    }
+    for _, addr := range addrs {
+        if !p.addrAllowed(addr) {
+            return fmt.Errorf("%w: %s resolves to %s", ErrBlockedTarget, host, addr.Unmap())
+        }
+    }
+    return nil
+}
+
+// DialContext dials like net.Dialer but refuses to connect to addresses the
+// policy blocks. The check runs on the resolved IP right before connecting,
+// so a hostname that is re-pointed at an internal address after validation
+// (DNS rebinding) is still caught. A nil policy dials anything.
+func (p *TargetPolicy) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+    dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+    host, _, err := net.SplitHostPort(address)
+    if err != nil {
+        return nil, err
+    }
+    if p != nil && !p.hostAllowed(host) {
+        dialer.Control = func(_, resolved string, _ syscall.RawConn) error {
+            ipStr, _, err := net.SplitHostPort(resolved)
+            if err != nil {
+                return err
+            }
+            addr, err := netip.ParseAddr(ipStr)
+            if err != nil {
+                return err
+            }
+            if !p.addrAllowed(addr) {
+                return fmt.Errorf("%w: %s resolves to %s", ErrBlockedTarget, host, addr.Unmap())
+            }
+            return nil
+        }
+    }
+    return dialer.DialContext(ctx, network, address)
+}
@@ -0,0 +1,90 @@
+package delivery
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// phaseTimings is how long each phase of an attempt took. Phases that did
+// not happen, such as DNS on a reused connection, are left invalid.
+type phaseTimings struct {
+    DNS     sql.NullInt64
+    Connect sql.NullInt64
+    TLS     sql.NullInt64
+    TTFB    sql.NullInt64
+    Reused  sql.NullBool
+}
+
+// attemptTrace collects httptrace events for one request. Callbacks may fire
+// from several goroutines (e.g. parallel dials), hence the mutex.
+type attemptTrace struct {
+    mu sync.Mutex
+
+    start        time.Time
+    dnsStart     time.Time
+    dnsDone      time.Time
+    connectStart time.Time
+    connectDone  time.Time
+    tlsStart     time.Time
+    tlsDone      time.Time
+    firstByte    time.Time
+    gotConn      bool
+    reused       bool
+    // sent is set once the request was handed to the HTTP client, as
+    // opposed to failing while it was being built.
+    sent bool
+}
+
+func newAttemptTrace(start time.Time) *attemptTrace {
+    return &attemptTrace{start: start}
+}
+
+func (t *attemptTrace) mark(field *time.Time, onlyFirst bool) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if onlyFirst && !field.IsZero() {
+        return
+    }
+    *field = time.Now()
+}
+
+func (t *attemptTrace) clientTrace() *httptrace.ClientTrace {
+    return &httptrace.ClientTrace{
+        DNSStart:          func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart, true) },
+        DNSDone:           func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone, false) },
+        ConnectStart:      func(string, string) { t.mark(&t.connectStart, true) },
+        ConnectDone:       func(string, string, error) { t.mark(&t.connectDone, false) },
+        TLSHandshakeStart: func() { t.mark(&t.tlsStart, true) },
+        TLSHandshakeDone:  func(tls.ConnectionState, error) { t.mark(&t.tlsDone, false) },
+        GotConn: func(info httptrace.GotConnInfo) {
+            t.mu.Lock()
+            defer t.mu.Unlock()
+            t.gotConn = true
+            t.reused = info.Reused
+        },
+        GotFirstResponseByte: func() { t.mark(&t.firstByte, true) },
+    }
+}
+
+// timings returns the phase durations seen so far.
+func (t *attemptTrace) timings() phaseTimings {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    return phaseTimings{
+        DNS:     phaseMs(t.dnsStart, t.dnsDone),
+        Connect: phaseMs(t.connectStart, t.connectDone),
+        TLS:     phaseMs(t.tlsStart, t.tlsDone),
+        TTFB:    phaseMs(t.start, t.firstByte),
+        Reused:  sql.NullBool{Bool: t.reused, Valid: t.gotConn},
+    }
+}
+
+func phaseMs(start, end time.Time) sql.NullInt64 {
+    if start.IsZero() || end.IsZero() {
+        return sql.NullInt64{}
+    }
+    return sql.NullInt64{Int64: end.Sub(start).Milliseconds(), Valid: true}
+}
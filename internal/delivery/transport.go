@@ -0,0 +1,139 @@
+package delivery
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+)
+
+// NewTLSConfig builds the client TLS configuration for a subscription from
+// PEM material. Every argument is optional: a client certificate and key for
+// mutual TLS, a CA bundle that replaces the system roots, and the hex SHA-256
+// fingerprint of the server certificate to pin.
+func NewTLSConfig(certPEM, keyPEM, caPEM, pinnedSHA256 string) (*tls.Config, error) {
+    cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+    if certPEM != "" || keyPEM != "" {
+        cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+        if err != nil {
+            return nil, fmt.Errorf("invalid client certificate or key: %w", err)
+        }
+        cfg.Certificates = []tls.Certificate{cert}
+    }
+    if caPEM != "" {
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM([]byte(caPEM)) {
+            return nil, errors.New("CA bundle contains no PEM certificates")
+        }
+        cfg.RootCAs = pool
+    }
+    if pinnedSHA256 != "" {
+        pin := strings.ToLower(pinnedSHA256)
+        cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+            if len(cs.PeerCertificates) == 0 {
+                return errors.New("server presented no certificate")
+            }
+            sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+            if hex.EncodeToString(sum[:]) != pin {
+                return errors.New("server certificate does not match the pinned fingerprint")
+            }
+            return nil
+        }
+    }
+    return cfg, nil
+}
+
+// hasCustomTLS reports whether sub needs its own transport.
+func hasCustomTLS(sub database.Subscription) bool {
+    return sub.TlsClientCert.Valid || sub.TlsCaBundle.Valid || sub.TlsPinnedCertSha256.Valid
+}
+
+// transportCache keeps one HTTP client per subscription with custom TLS, so
+// connections are reused between deliveries. An entry is rebuilt when the
+// subscription's TLS settings change.
+type transportCache struct {
+    mu      sync.Mutex
+    clients map[string]cachedClient
+}
+
+type cachedClient struct {
+    version string
+    client  *http.Client
+}
+
+// clientFor returns the HTTP client to deliver to sub with: the shared
+// client, or a dedicated one carrying the subscription's TLS settings.
+func (w *Worker) clientFor(sub database.Subscription) (*http.Client, error) {
+    if !hasCustomTLS(sub) {
+        return w.HTTPClient, nil
+    }
+    version := tlsVersion(sub)
+
+    w.transports.mu.Lock()
+    defer w.transports.mu.Unlock()
+    if cached, ok := w.transports.clients[sub.ID]; ok && cached.version == version {
+        return cached.client, nil
+    }
+
+    var keyPEM string
+    if sub.TlsClientKeyEncrypted.Valid {
+        var err error
+        keyPEM, err = w.Secrets.Decrypt(sub.TlsClientKeyEncrypted.String)
+        if err != nil {
+            return nil, fmt.Errorf("client key: %w", err)
+        }
+    }
+    cfg, err := NewTLSConfig(sub.TlsClientCert.String, keyPEM, sub.TlsCaBundle.String, sub.TlsPinnedCertSha256.String)
+    if err != nil {
+        return nil, err
+    }
+    transport := w.newTransport()
+    transport.TLSClientConfig = cfg
+    client := &http.Client{Timeout: w.HTTPClient.Timeout, Transport: transport}
+
+    if w.transports.clients == nil {
+        w.transports.clients = make(map[string]cachedClient)
+    }
+    if old, ok := w.transports.clients[sub.ID]; ok {
+        old.client.CloseIdleConnections()
+    }
+    w.transports.clients[sub.ID] = cachedClient{version: version, client: client}
+    return client, nil
+}
+
+// newTransport returns a transport that dials through the worker's target
+// policy. The policy is read on every dial so it can be swapped after
+// NewWorker. HTTP(S)_PROXY is ignored: through a proxy the policy would
+// only see the proxy's address, not the target's.
+func (w *Worker) newTransport() *http.Transport {
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    transport.Proxy = nil
+    transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+        return w.Targets.DialContext(ctx, network, address)
+    }
+    return transport
+}
+
+// tlsVersion fingerprints the TLS columns of sub.
+func tlsVersion(sub database.Subscription) string {
+    h := sha256.New()
+    for _, v := range []string{
+        sub.TlsClientCert.String,
+        sub.TlsClientKeyEncrypted.String,
+        sub.TlsCaBundle.String,
+        sub.TlsPinnedCertSha256.String,
+    } {
+        h.Write([]byte(v))
+        h.Write([]byte{0})
+    }
+    return hex.EncodeToString(h.Sum(nil))
+}
@@ -4,185 +4,543 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/cache"
 	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/secrets"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/transform"
 	"github.com/google/uuid"
 )
 
-const (
-    maxAttempts = 5
-)
-
 type Worker struct {
     Queries *database.Queries
     Cache   *cache.RedisSubscriptionCache
     HTTPClient *http.Client 
+    // ID identifies this worker as the lease owner of the tasks it claims.
+    ID string
+    // LeaseDuration is how long a claimed task stays reserved for this worker
+    // before another instance is allowed to reclaim it.
+    LeaseDuration time.Duration
+    // Concurrency is the number of deliveries this worker runs in parallel.
+    Concurrency int
+    // MaxInFlightPerSubscription caps how many tasks of one subscription may
+    // be in flight at once across all instances, so a slow target cannot
+    // occupy the whole pool.
+    MaxInFlightPerSubscription int
+    // PollInterval is how often the worker looks for tasks to fill free slots.
+    PollInterval time.Duration
+    // PermanentStatusCodes are response codes that dead-letter a task
+    // immediately instead of retrying it.
+    PermanentStatusCodes map[int]bool
+    // Breakers stops deliveries to target hosts that keep failing.
+    Breakers *CircuitBreakers
+    // Limiter enforces each subscription's outbound rate limit.
+    Limiter *cache.RedisRateLimiter
+    // ResponseBodyLimit is how many bytes of each response body are kept in
+    // the delivery log.
+    ResponseBodyLimit int
+    // CapturedResponseHeaders lists the response headers kept in the
+    // delivery log.
+    CapturedResponseHeaders []string
+    // Secrets decrypts stored key material such as mTLS client keys and
+    // outbound credentials.
+    Secrets *secrets.Box
+    // Targets blocks deliveries to private and internal addresses.
+    Targets *TargetPolicy
+
+    transports transportCache
+    tokens     tokenCache
+
+    inFlight atomic.Int64
 }
 
 func NewWorker(queries *database.Queries, cache *cache.RedisSubscriptionCache) *Worker {
-    return &Worker{Queries: queries, Cache: cache, HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+    w := &Worker{
+        Queries:                    queries,
+        Cache:                      cache,
+        ID:                         newWorkerID(),
+        LeaseDuration:              envDuration("DELIVERY_LEASE_DURATION", 2*time.Minute),
+        Concurrency:                envInt("DELIVERY_CONCURRENCY", 10),
+        MaxInFlightPerSubscription: envInt("DELIVERY_MAX_IN_FLIGHT_PER_SUBSCRIPTION", 2),
+        PollInterval:               envDuration("DELIVERY_POLL_INTERVAL", 1*time.Second),
+        PermanentStatusCodes:       permanentStatusCodesFromEnv(),
+        Breakers: NewCircuitBreakers(
+            envInt("DELIVERY_BREAKER_FAILURE_THRESHOLD", 5),
+            envDuration("DELIVERY_BREAKER_OPEN_DURATION", 30*time.Second),
+        ),
+        Limiter:                    cache.RateLimiter(),
+        ResponseBodyLimit:          envInt("DELIVERY_RESPONSE_BODY_LIMIT", 4096),
+        CapturedResponseHeaders:    envList("DELIVERY_CAPTURED_RESPONSE_HEADERS", defaultCapturedResponseHeaders),
+        Targets:                    &TargetPolicy{},
+    }
+    w.HTTPClient = &http.Client{Timeout: 10 * time.Second, Transport: w.newTransport()}
+    return w
+}
+
+// deliveryJob is one outbound request's worth of work: a single task, or
+// a batch of tasks for a batching subscription.
+type deliveryJob struct {
+    tasks   []database.DeliveryTask
+    batched bool
 }
 
+// Start claims and delivers tasks until ctx is cancelled. It then stops
+// claiming and returns once the deliveries already running have finished.
 func (w *Worker) Start(ctx context.Context) {
-    ticker := time.NewTicker(5 * time.Second)
+    // Deliveries that already started run to completion even after ctx is
+    // cancelled, so their attempts are always logged.
+    jobCtx := context.WithoutCancel(ctx)
+    jobs := make(chan deliveryJob)
+    var wg sync.WaitGroup
+    for i := 0; i < w.Concurrency; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for job := range jobs {
+                if job.batched {
+                    w.processBatch(jobCtx, job.tasks)
+                } else {
+                    w.processTask(jobCtx, job.tasks[0])
+                }
+                w.inFlight.Add(-1)
+            }
+        }()
+    }
+    defer wg.Wait()
+    defer close(jobs)
+
+    ticker := time.NewTicker(w.PollInterval)
     defer ticker.Stop()
 
     for {
         select {
         case <-ticker.C:
-            w.processPendingTasks(ctx)
+            w.dispatchPendingTasks(ctx, jobs)
         case <-ctx.Done():
             return
         }
     }
 }
 
-func (w *Worker) processPendingTasks(ctx context.Context) {
-    tasks, err := w.Queries.ListPendingDeliveryTasks(ctx)
+// dispatchPendingTasks claims as many due tasks and ready batches as there
+// are idle delivery goroutines and hands them to the pool.
+func (w *Worker) dispatchPendingTasks(ctx context.Context, jobs chan<- deliveryJob) {
+    free := w.Concurrency - int(w.inFlight.Load())
+    free -= w.dispatchBatches(ctx, jobs, free)
+    if free <= 0 {
+        return
+    }
+
+    now := time.Now()
+    claimed, err := w.Queries.ClaimDeliveryTasks(ctx, database.ClaimDeliveryTasksParams{
+        LeaseOwner:         sql.NullString{String: w.ID, Valid: true},
+        LeaseExpiresAt:     sql.NullTime{Time: now.Add(w.LeaseDuration), Valid: true},
+        Now:                sql.NullTime{Time: now, Valid: true},
+        MaxPerSubscription: int64(w.MaxInFlightPerSubscription),
+        BatchSize:          int64(free),
+    })
     if err != nil {
-        log.Printf("error fetching pending tasks: %v", err)
+        log.Printf("error claiming pending tasks: %v", err)
+        return
+    }
+
+    for _, task := range claimed {
+        w.inFlight.Add(1)
+        jobs <- deliveryJob{tasks: []database.DeliveryTask{task}}
+    }
+}
+
+func (w *Worker) processTask(ctx context.Context, task database.DeliveryTask) {
+    sub, err := w.subscriptionFor(ctx, task.SubscriptionID)
+    if err != nil {
+        w.subscriptionUnavailable(ctx, task, err)
+        return
+    }
+
+    payload, err := preparePayload(sub, task)
+    if err != nil {
+        w.recordAttempt(ctx, sub, task, transformFailure(err), "")
+        return
+    }
+
+    host := TargetHost(sub.TargetUrl)
+    if allowed, retryAt := w.admit(ctx, sub, host); !allowed {
+        w.deferTask(ctx, task, retryAt)
         return
     }
 
-    for _, task := range tasks {
-        var sub database.Subscription
-        var ok bool
+    result := w.deliverWebhook(sub, headerVars{
+        EventType: task.EventType.String,
+        TaskID:    task.ID,
+        Attempt:   task.AttemptCount + 1,
+    }, payload)
+    w.recordBreaker(host, result)
+    w.recordAttempt(ctx, sub, task, result, "")
+}
+
+// subscriptionFor loads a subscription from the cache, falling back to the
+// database.
+func (w *Worker) subscriptionFor(ctx context.Context, id string) (database.Subscription, error) {
+    var sub database.Subscription
+    var ok bool
+    var err error
+    if w.Cache != nil {
+        sub, ok = w.Cache.Get(id)
+    }
+    if !ok {
+        sub, err = w.Queries.GetSubscription(ctx, id)
+        if err != nil {
+            return sub, err
+        }
         if w.Cache != nil {
-            sub, ok = w.Cache.Get(task.SubscriptionID)
-       }
-        if !ok {
-            sub, err = w.Queries.GetSubscription(ctx, task.SubscriptionID)
-            if err != nil {
-                log.Printf("error fetching subscription for task %s: %v", task.ID, err)
-                continue
-            }
-            w.Cache.Set(task.SubscriptionID, sub)
+            w.Cache.Set(id, sub)
         }
+    }
+    return sub, nil
+}
 
-        status, httpStatus, errMsg := w.deliverWebhook(sub.TargetUrl, []byte(task.Payload))
-        attempt := task.AttemptCount + 1
-
-        err = w.Queries.CreateDeliveryLog(ctx, database.CreateDeliveryLogParams{
-            ID:             generateUUID(),
-            DeliveryTaskID: task.ID,
-            SubscriptionID: task.SubscriptionID,
-            TargetUrl:      sub.TargetUrl,
-            Timestamp:      time.Now(),
-            AttemptNumber:  int64(attempt),
-            Outcome:        status,
-            HttpStatus: sql.NullInt64{
-                Int64: int64(httpStatus),
-                Valid: httpStatus != 0,
-            },
-            ErrorDetails: sql.NullString{
-                String: errMsg,
-                Valid:  errMsg != "",
-            },
-        })
+// recordBreaker feeds the outcome of a request to the target host's circuit
+// breaker. Only transport errors and 5xx responses suggest the host itself
+// is down; a request that never went out (bad auth or TLS settings, a
+// failed token fetch, a blocked address) says nothing about the host.
+func (w *Worker) recordBreaker(host string, result deliveryResult) {
+    if !result.ReachedTarget {
+        return
+    }
+    w.Breakers.Record(host, result.HTTPStatus != 0 && result.HTTPStatus < 500, time.Now())
+}
+
+// subscriptionRetryDelay is how long a task waits when its subscription
+// could not be loaded, e.g. because the database was briefly unreachable.
+const subscriptionRetryDelay = 10 * time.Second
+
+// subscriptionUnavailable settles a claimed task whose subscription could not
+// be loaded. A deleted subscription will never come back, so the task is
+// dead-lettered; any other error hands it back for another try shortly.
+func (w *Worker) subscriptionUnavailable(ctx context.Context, task database.DeliveryTask, err error) {
+    if errors.Is(err, sql.ErrNoRows) {
+        w.recordAttempt(ctx, database.Subscription{ID: task.SubscriptionID}, task, deliveryResult{
+            Status:         "failed_attempt",
+            ErrMsg:         "subscription not found",
+            Classification: classificationPermanent,
+        }, "")
+        return
+    }
+    log.Printf("error fetching subscription for task %s, retrying in %s: %v", task.ID, subscriptionRetryDelay, err)
+    w.deferTask(ctx, task, time.Now().Add(subscriptionRetryDelay))
+}
+
+// admit checks the subscription's rate limit and the target host's circuit
+// breaker. When a request may not be sent now it returns false and the time
+// to try again.
+func (w *Worker) admit(ctx context.Context, sub database.Subscription, host string) (bool, time.Time) {
+    // Check the rate limit before the breaker: a half-open breaker hands out
+    // its single probe slot on Allow, which must not be wasted on a task that
+    // is then postponed.
+    if sub.RateLimitPerSecond.Valid {
+        allowed, wait, err := w.Limiter.Allow(ctx, sub.ID, sub.RateLimitPerSecond.Float64, int(sub.RateLimitBurst.Int64))
         if err != nil {
-            log.Printf("error logging delivery attempt for task %s: %v", task.ID, err)
+            log.Printf("rate limiter unavailable for subscription %s, delivering anyway: %v", sub.ID, err)
+        } else if !allowed {
+            return false, time.Now().Add(wait)
         }
+    }
+    if allowed, retryAt := w.Breakers.Allow(host, time.Now()); !allowed {
+        return false, retryAt
+    }
+    return true, time.Time{}
+}
 
-        newStatus := task.Status
-        if status == "success" {
-            newStatus = "delivered"
-        } else if attempt >= maxAttempts {
-            newStatus = "failed"
+// recordAttempt logs one delivery attempt for task and moves the task on:
+// back to pending for a retry, delivered, or into the dead letter queue.
+// batchID is set when the attempt was part of a batched request.
+func (w *Worker) recordAttempt(ctx context.Context, sub database.Subscription, task database.DeliveryTask, result deliveryResult, batchID string) {
+    attempt := task.AttemptCount + 1
+    policy := retryPolicyFor(sub)
+    // Permanent failures skip the remaining attempts and go straight to the DLQ.
+    exhausted := result.Classification == classificationPermanent || attempt >= int64(policy.MaxAttempts)
+
+    err := w.Queries.CreateDeliveryLog(ctx, database.CreateDeliveryLogParams{
+        ID:             generateUUID(),
+        DeliveryTaskID: task.ID,
+        SubscriptionID: task.SubscriptionID,
+        TargetUrl:      sub.TargetUrl,
+        Timestamp:      time.Now(),
+        AttemptNumber:  int64(attempt),
+        Outcome:        result.Status,
+        HttpStatus: sql.NullInt64{
+            Int64: int64(result.HTTPStatus),
+            Valid: result.HTTPStatus != 0,
+        },
+        ErrorDetails: sql.NullString{
+            String: result.ErrMsg,
+            Valid:  result.ErrMsg != "",
+        },
+        SignatureScheme: sql.NullString{
+            String: signatureScheme(sub),
+            Valid:  true,
+        },
+        Classification: sql.NullString{
+            String: result.Classification,
+            Valid:  true,
+        },
+        BatchID: sql.NullString{
+            String: batchID,
+            Valid:  batchID != "",
+        },
+        LatencyMs: sql.NullInt64{
+            Int64: result.Latency.Milliseconds(),
+            Valid: result.Latency > 0,
+        },
+        ResponseBody:     truncateBody(result.ResponseBody, w.ResponseBodyLimit),
+        ResponseHeaders:  encodeHeaders(result.ResponseHeaders),
+        DnsMs:            result.Timings.DNS,
+        ConnectMs:        result.Timings.Connect,
+        TlsMs:            result.Timings.TLS,
+        TtfbMs:           result.Timings.TTFB,
+        ConnectionReused: result.Timings.Reused,
+    })
+    if err != nil {
+        log.Printf("error logging delivery attempt for task %s: %v", task.ID, err)
+    }
+
+    newStatus := "pending"
+    if result.Status == "success" {
+        newStatus = "delivered"
+    } else if exhausted {
+        newStatus = "failed"
+    }
+
+    // The retry is scheduled in the same lease-guarded update that releases
+    // the lease, so another worker cannot pick the task up again
+    // immediately, and a worker that lost the lease changes nothing.
+    nextAttempt := task.NextAttemptAt
+    if result.Status != "success" && !exhausted {
+        backoff := policy.Backoff(int(attempt))
+        if result.RetryAfter > 0 {
+            backoff = result.RetryAfter
         }
+        nextAttempt = sql.NullTime{Time: time.Now().Add(backoff), Valid: true}
+    }
 
-        err = w.Queries.UpdateDeliveryTaskStatus(ctx, database.UpdateDeliveryTaskStatusParams{
-            Status: newStatus,
-            LastAttemptAt: sql.NullTime{
+    updated, err := w.Queries.UpdateDeliveryTaskStatus(ctx, database.UpdateDeliveryTaskStatusParams{
+        Status: newStatus,
+        LastAttemptAt: sql.NullTime{
+            Time:  time.Now(),
+            Valid: true,
+        },
+        AttemptCount:  int64(attempt),
+        NextAttemptAt: nextAttempt,
+        ID:            task.ID,
+        LeaseOwner:    sql.NullString{String: w.ID, Valid: true},
+    })
+    if err != nil {
+        log.Printf("error updating task status for %s: %v", task.ID, err)
+    } else if updated == 0 {
+        log.Printf("lease on task %s was lost before its result could be recorded", task.ID)
+        return
+    }
+    
+    
+    if result.Status != "success" && exhausted {
+        reason := result.ErrMsg
+        if result.Classification == classificationPermanent {
+            reason = "permanent failure: " + result.ErrMsg
+        }
+        dlqErr := w.Queries.InsertDeadLetterTask(ctx, database.InsertDeadLetterTaskParams{
+            ID:              generateUUID(),
+            OriginalTaskID:  task.ID,
+            SubscriptionID:  task.SubscriptionID,
+            Payload:         task.Payload,
+            FailedAt:        time.Now(),
+            Reason:          reason,
+            LastAttemptAt:   sql.NullTime{
                 Time:  time.Now(),
                 Valid: true,
             },
-            AttemptCount: int64(attempt),
-            ID:           task.ID,
+            AttemptCount:    int64(attempt),
+            Status:          "pending",
+            TargetUrl:       sql.NullString{
+                String: sub.TargetUrl,
+                Valid:  sub.TargetUrl != "",
+            },
+            EventType:       task.EventType,
+            ErrorDetails:    sql.NullString{String: result.ErrMsg, Valid: result.ErrMsg != ""},
         })
-        if err != nil {
-            log.Printf("error updating task status for %s: %v", task.ID, err)
-        }
-        
-        
-        if status != "success" && attempt >= maxAttempts {
-            dlqErr := w.Queries.InsertDeadLetterTask(ctx, database.InsertDeadLetterTaskParams{
-                ID:              generateUUID(),
-                OriginalTaskID:  task.ID,
-                SubscriptionID:  task.SubscriptionID,
-                Payload:         task.Payload,
-                FailedAt:        time.Now(),
-                Reason:          errMsg,
-                LastAttemptAt:   sql.NullTime{
-                    Time:  time.Now(),
-                    Valid: true,
-                },
-                AttemptCount:    int64(attempt),
-                Status:          "pending",
-                TargetUrl:       sql.NullString{
-                    String: sub.TargetUrl,
-                    Valid:  sub.TargetUrl != "",
-                },
-                EventType:       sql.NullString{
-                    String: "",
-                    Valid:  false, 
-                },
-                ErrorDetails:    sql.NullString{String: errMsg, Valid: errMsg != ""},
-            })
-            if dlqErr != nil {
-                log.Printf("error inserting into dead letter queue for task %s: %v", task.ID, dlqErr)
-            } else {
-                log.Printf("Task %s moved to dead letter queue after %d attempts", task.ID, attempt)
-            }
+        if dlqErr != nil {
+            log.Printf("error inserting into dead letter queue for task %s: %v", task.ID, dlqErr)
+        } else {
+            log.Printf("Task %s moved to dead letter queue after %d attempts (%s)", task.ID, attempt, result.Classification)
         }
+    }
+}
 
-        if status != "success" && attempt < maxAttempts {
-            backoff := getBackoffDuration(int(attempt))
-            nextAttempt := time.Now().Add(backoff)
-            
-            err = w.Queries.UpdateDeliveryTaskNextAttemptAt(ctx, database.UpdateDeliveryTaskNextAttemptAtParams{
-                ID: task.ID,
-                NextAttemptAt: sql.NullTime{
-                    Time: nextAttempt,
-                    Valid: true,
-                },
-            })
-            if err != nil {
-                log.Printf("Error updating next attempt time: %v", err)
-            }
-        }
+// deferTask returns a claimed task to the queue until retryAt without
+// counting it as an attempt.
+func (w *Worker) deferTask(ctx context.Context, task database.DeliveryTask, retryAt time.Time) {
+    _, err := w.Queries.DeferDeliveryTask(ctx, database.DeferDeliveryTaskParams{
+        NextAttemptAt: sql.NullTime{Time: retryAt, Valid: true},
+        ID:            task.ID,
+        LeaseOwner:    sql.NullString{String: w.ID, Valid: true},
+    })
+    if err != nil {
+        log.Printf("error deferring task %s: %v", task.ID, err)
     }
 }
 
-func(w *Worker) deliverWebhook(targetURL string, payload []byte) (status string, httpStatus int, errMsg string) {
-    resp, err := w.HTTPClient.Post(targetURL, "application/json", bytes.NewBuffer(payload))
+// deliveryResult describes the outcome of a single delivery attempt.
+type deliveryResult struct {
+    Status         string // success, failed_attempt
+    HTTPStatus     int
+    ErrMsg         string
+    Classification string
+    // ReachedTarget is set when the request was sent to the target host,
+    // whether or not it answered.
+    ReachedTarget bool
+    // RetryAfter is the delay the target asked for on a throttled response.
+    RetryAfter time.Duration
+    // ResponseBody holds up to maxResponseBodySize bytes of the response.
+    ResponseBody []byte
+    // ResponseHeaders holds the captured subset of the response headers.
+    ResponseHeaders http.Header
+    // Latency is the time from sending the request to reading the response.
+    Latency time.Duration
+    // Timings breaks the request down into DNS, connect, TLS and TTFB.
+    Timings phaseTimings
+}
+
+// preparePayload returns the body to deliver for task: its payload after
+// the subscription's transform, wrapped in an envelope when the signing
+// profile calls for one.
+func preparePayload(sub database.Subscription, task database.DeliveryTask) ([]byte, error) {
+    payload, err := transform.Apply(sub.Transform.String, []byte(task.Payload))
     if err != nil {
-        return "failed_attempt", 0, err.Error()
+        return nil, err
     }
-    defer resp.Body.Close()
+    if sub.SigningProfile == SigningProfileStandard {
+        return standardEnvelope(task, payload)
+    }
+    return payload, nil
+}
 
-    if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-        return "success", resp.StatusCode, ""
+// transformFailure is the result for a payload the subscription's transform
+// cannot be applied to. Retrying would fail the same way, so the task goes
+// straight to the DLQ.
+func transformFailure(err error) deliveryResult {
+    return deliveryResult{
+        Status:         "failed_attempt",
+        ErrMsg:         "transformation failed: " + err.Error(),
+        Classification: classificationPermanent,
     }
-    return "failed_attempt", resp.StatusCode, resp.Status
 }
 
-func getBackoffDuration(attempt int) time.Duration {
-    switch attempt {
-    case 1:
-        return 10 * time.Second
-    case 2:
-        return 30 * time.Second
-    case 3:
-        return 1 * time.Minute
-    case 4:
-        return 5 * time.Minute
+// maxResponseBodySize bounds how much of a target's response is read.
+const maxResponseBodySize = 1 << 20
+
+func(w *Worker) deliverWebhook(sub database.Subscription, vars headerVars, payload []byte) deliveryResult {
+    client, err := w.clientFor(sub)
+    if err != nil {
+        return deliveryResult{Status: "failed_attempt", ErrMsg: "TLS configuration: " + err.Error(), Classification: classificationRetryable}
+    }
+    auth, err := w.authFor(sub)
+    if err != nil {
+        return deliveryResult{Status: "failed_attempt", ErrMsg: "auth configuration: " + err.Error(), Classification: classificationRetryable}
+    }
+
+    resp, trace, err := w.send(client, sub, auth, vars, payload)
+    if err == nil && resp.StatusCode == http.StatusUnauthorized && auth != nil && auth.Type == AuthOAuth2 {
+        // The token may have been revoked or expired early: fetch a fresh
+        // one and try once more within the same attempt.
+        resp.Body.Close()
+        w.tokens.invalidate(sub.ID)
+        resp, trace, err = w.send(client, sub, auth, vars, payload)
+    }
+    if err != nil {
+        classification := classificationRetryable
+        if errors.Is(err, ErrBlockedTarget) {
+            classification = classificationPermanent
+        }
+        return deliveryResult{
+            Status:         "failed_attempt",
+            ErrMsg:         err.Error(),
+            Classification: classification,
+            ReachedTarget:  trace.sent && !errors.Is(err, ErrBlockedTarget),
+            Latency:        time.Since(trace.start),
+            Timings:        trace.timings(),
+        }
+    }
+    defer resp.Body.Close()
+
+    body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+
+    result := deliveryResult{
+        ReachedTarget:   true,
+        HTTPStatus:      resp.StatusCode,
+        Classification:  classifyResponse(resp.StatusCode, w.PermanentStatusCodes),
+        ResponseBody:    body,
+        ResponseHeaders: captureHeaders(resp.Header, w.CapturedResponseHeaders),
+        Latency:         time.Since(trace.start),
+        Timings:         trace.timings(),
+    }
+    switch result.Classification {
+    case classificationSuccess:
+        result.Status = "success"
+    case classificationThrottled:
+        result.Status = "failed_attempt"
+        result.ErrMsg = resp.Status
+        result.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
     default:
-        return 15 * time.Minute
+        result.Status = "failed_attempt"
+        result.ErrMsg = resp.Status
+    }
+    return result
+}
+
+// send builds one request for sub, adds custom headers, credentials and the
+// signature, and sends it with a trace attached.
+func (w *Worker) send(client *http.Client, sub database.Subscription, auth *AuthConfig, vars headerVars, payload []byte) (*http.Response, *attemptTrace, error) {
+    trace := newAttemptTrace(time.Now())
+    req, err := http.NewRequest(http.MethodPost, sub.TargetUrl, bytes.NewBuffer(payload))
+    if err != nil {
+        return nil, trace, err
+    }
+    now := time.Now()
+    vars.Timestamp = now.Unix()
+    req.Header.Set("Content-Type", "application/json")
+    applyHeaders(req, sub.Headers, vars)
+    if err := w.applyAuth(req, sub, auth); err != nil {
+        return nil, trace, err
+    }
+    if err := signRequest(req, sub, vars.TaskID, payload, now); err != nil {
+        return nil, trace, fmt.Errorf("signing request: %w", err)
+    }
+
+    // Time the request itself, not the token fetch above.
+    trace = newAttemptTrace(time.Now())
+    trace.sent = true
+    req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+    resp, err := client.Do(req)
+    return resp, trace, err
+}
+
+// newWorkerID returns a lease owner ID that is unique per process, prefixed
+// with the hostname so leases can be traced back to an instance.
+func newWorkerID() string {
+    host, err := os.Hostname()
+    if err != nil || host == "" {
+        host = "worker"
     }
+    return host + "-" + uuid.New().String()[:8]
 }
 
 func generateUUID() string {
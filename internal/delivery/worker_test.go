@@ -0,0 +1,91 @@
+package delivery
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/database"
+	"github.com/KrishKoria/Webhook-Delivery-Service/internal/secrets"
+)
+
+// newTestWorker returns a worker whose breakers open on the first failure
+// and whose target policy lets it reach httptest servers on loopback.
+func newTestWorker(t *testing.T) *Worker {
+    t.Helper()
+    box, err := secrets.NewBox(make([]byte, 32))
+    if err != nil {
+        t.Fatal(err)
+    }
+    targets, err := NewTargetPolicy([]string{"127.0.0.1"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    w := &Worker{
+        Breakers: NewCircuitBreakers(1, time.Minute),
+        Secrets:  box,
+        Targets:  targets,
+    }
+    w.HTTPClient = &http.Client{Timeout: 5 * time.Second, Transport: w.newTransport()}
+    return w
+}
+
+func TestTokenFetchFailureLeavesBreakerClosed(t *testing.T) {
+    w := newTestWorker(t)
+    tokenServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+        rw.WriteHeader(http.StatusInternalServerError)
+    }))
+    defer tokenServer.Close()
+    targetHits := 0
+    target := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+        targetHits++
+    }))
+    defer target.Close()
+
+    cfg, _ := json.Marshal(AuthConfig{Type: AuthOAuth2, TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret"})
+    encrypted, err := w.Secrets.Encrypt(string(cfg))
+    if err != nil {
+        t.Fatal(err)
+    }
+    sub := database.Subscription{
+        ID:                  "sub",
+        TargetUrl:           target.URL,
+        AuthType:            sql.NullString{String: AuthOAuth2, Valid: true},
+        AuthConfigEncrypted: sql.NullString{String: encrypted, Valid: true},
+    }
+
+    result := w.deliverWebhook(sub, headerVars{TaskID: "task", Attempt: 1}, []byte(`{}`))
+    if result.Status == "success" || result.ReachedTarget {
+        t.Fatalf("expected a failure before the request was sent, got %+v", result)
+    }
+    host := TargetHost(sub.TargetUrl)
+    w.recordBreaker(host, result)
+    if state := w.Breakers.State(host); state != BreakerClosed {
+        t.Fatalf("breaker for %s is %s after a token fetch failure, want %s", host, state, BreakerClosed)
+    }
+    if targetHits != 0 {
+        t.Fatalf("target was called %d times", targetHits)
+    }
+}
+
+func TestTargetServerErrorOpensBreaker(t *testing.T) {
+    w := newTestWorker(t)
+    target := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+        rw.WriteHeader(http.StatusBadGateway)
+    }))
+    defer target.Close()
+    sub := database.Subscription{ID: "sub", TargetUrl: target.URL}
+
+    result := w.deliverWebhook(sub, headerVars{TaskID: "task", Attempt: 1}, []byte(`{}`))
+    if !result.ReachedTarget {
+        t.Fatalf("expected the request to reach the target, got %+v", result)
+    }
+    host := TargetHost(sub.TargetUrl)
+    w.recordBreaker(host, result)
+    if state := w.Breakers.State(host); state != BreakerOpen {
+        t.Fatalf("breaker for %s is %s after a 502, want %s", host, state, BreakerOpen)
+    }
+}
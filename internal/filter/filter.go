@@ -0,0 +1,483 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a parsed filter expression over an event's JSON payload, e.g.
+//
+//	amount > 1000 && currency == "USD"
+//	customer.tier in ["gold", "platinum"]
+//	!(items[0].sku == "test") || metadata["source-system"] != null
+//
+// Supported: field paths with .name, [index] and ["key"], string, number,
+// true/false/null and list literals, the comparisons == != < <= > >= and
+// in, and &&, || and ! with parentheses. A missing field is null. A
+// comparison between mismatched types is false rather than an error.
+type Expr struct {
+    src  string
+    root node
+}
+
+// Parse compiles src.
+func Parse(src string) (*Expr, error) {
+    p := &parser{lex: lexer{src: src}}
+    p.next()
+    root, err := p.parseOr()
+    if err == nil {
+        err = p.err
+    }
+    if err != nil {
+        return nil, err
+    }
+    if p.tok.kind != tokEOF {
+        return nil, p.errorf("unexpected %s", p.tok)
+    }
+    return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+    return e.src
+}
+
+// Match reports whether payload satisfies the expression. It fails only
+// when payload is not JSON.
+func (e *Expr) Match(payload []byte) (bool, error) {
+    var doc interface{}
+    if err := json.Unmarshal(payload, &doc); err != nil {
+        return false, fmt.Errorf("payload is not valid JSON")
+    }
+    return truthy(e.root.eval(doc)), nil
+}
+
+// node is one element of the expression tree; eval returns a JSON-like
+// value: nil, bool, float64, string, []interface{} or map[string]interface{}.
+type node interface {
+    eval(doc interface{}) interface{}
+}
+
+type literal struct{ value interface{} }
+
+func (n literal) eval(interface{}) interface{} { return n.value }
+
+type listNode []node
+
+func (n listNode) eval(doc interface{}) interface{} {
+    out := make([]interface{}, len(n))
+    for i, item := range n {
+        out[i] = item.eval(doc)
+    }
+    return out
+}
+
+// pathNode looks up a field; each step is a string key or an int index.
+type pathNode []interface{}
+
+func (n pathNode) eval(doc interface{}) interface{} {
+    cur := doc
+    for _, step := range n {
+        switch s := step.(type) {
+        case string:
+            obj, ok := cur.(map[string]interface{})
+            if !ok {
+                return nil
+            }
+            cur = obj[s]
+        case int:
+            arr, ok := cur.([]interface{})
+            if !ok || s >= len(arr) {
+                return nil
+            }
+            cur = arr[s]
+        }
+    }
+    return cur
+}
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(doc interface{}) interface{} { return !truthy(n.operand.eval(doc)) }
+
+type logicalNode struct {
+    and         bool
+    left, right node
+}
+
+func (n logicalNode) eval(doc interface{}) interface{} {
+    if n.and {
+        return truthy(n.left.eval(doc)) && truthy(n.right.eval(doc))
+    }
+    return truthy(n.left.eval(doc)) || truthy(n.right.eval(doc))
+}
+
+type compareNode struct {
+    op          string
+    left, right node
+}
+
+func (n compareNode) eval(doc interface{}) interface{} {
+    l, r := n.left.eval(doc), n.right.eval(doc)
+    switch n.op {
+    case "==":
+        return equal(l, r)
+    case "!=":
+        return !equal(l, r)
+    case "in":
+        list, ok := r.([]interface{})
+        if !ok {
+            return false
+        }
+        for _, item := range list {
+            if equal(l, item) {
+                return true
+            }
+        }
+        return false
+    }
+    if lf, ok := l.(float64); ok {
+        rf, ok := r.(float64)
+        if !ok {
+            return false
+        }
+        return ordered(n.op, compareFloats(lf, rf))
+    }
+    if ls, ok := l.(string); ok {
+        rs, ok := r.(string)
+        if !ok {
+            return false
+        }
+        return ordered(n.op, strings.Compare(ls, rs))
+    }
+    return false
+}
+
+func compareFloats(a, b float64) int {
+    switch {
+    case a < b:
+        return -1
+    case a > b:
+        return 1
+    }
+    return 0
+}
+
+func ordered(op string, cmp int) bool {
+    switch op {
+    case "<":
+        return cmp < 0
+    case "<=":
+        return cmp <= 0
+    case ">":
+        return cmp > 0
+    case ">=":
+        return cmp >= 0
+    }
+    return false
+}
+
+// equal compares scalars by value; lists and objects never compare equal.
+func equal(a, b interface{}) bool {
+    switch a.(type) {
+    case nil, bool, float64, string:
+        return a == b
+    }
+    return false
+}
+
+func truthy(v interface{}) bool {
+    switch b := v.(type) {
+    case bool:
+        return b
+    case nil:
+        return false
+    }
+    return true
+}
+
+// parser is a recursive-descent parser, lowest precedence first:
+// ||, &&, !, comparisons, operands.
+type parser struct {
+    lex lexer
+    tok token
+    err error
+}
+
+func (p *parser) next() {
+    if p.err != nil {
+        p.tok = token{kind: tokEOF}
+        return
+    }
+    p.tok, p.err = p.lex.next()
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+    if p.err != nil {
+        return p.err
+    }
+    return fmt.Errorf("filter: "+format+" at position %d", append(args, p.tok.pos+1)...)
+}
+
+func (p *parser) parseOr() (node, error) {
+    left, err := p.parseAnd()
+    for err == nil && p.tok.is(tokOp, "||") {
+        p.next()
+        var right node
+        right, err = p.parseAnd()
+        left = logicalNode{left: left, right: right}
+    }
+    return left, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+    left, err := p.parseNot()
+    for err == nil && p.tok.is(tokOp, "&&") {
+        p.next()
+        var right node
+        right, err = p.parseNot()
+        left = logicalNode{and: true, left: left, right: right}
+    }
+    return left, err
+}
+
+func (p *parser) parseNot() (node, error) {
+    if p.tok.is(tokOp, "!") {
+        p.next()
+        operand, err := p.parseNot()
+        return notNode{operand}, err
+    }
+    return p.parseComparison()
+}
+
+var comparisonOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+func (p *parser) parseComparison() (node, error) {
+    left, err := p.parseOperand()
+    if err != nil {
+        return nil, err
+    }
+    var op string
+    switch {
+    case p.tok.kind == tokOp && comparisonOps[p.tok.text]:
+        op = p.tok.text
+    case p.tok.is(tokIdent, "in"):
+        op = "in"
+    default:
+        return left, nil
+    }
+    p.next()
+    right, err := p.parseOperand()
+    if err != nil {
+        return nil, err
+    }
+    return compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+    tok := p.tok
+    switch tok.kind {
+    case tokNumber:
+        p.next()
+        f, err := strconv.ParseFloat(tok.text, 64)
+        if err != nil {
+            return nil, p.errorf("invalid number %q", tok.text)
+        }
+        return literal{f}, p.err
+    case tokString:
+        p.next()
+        return literal{tok.text}, p.err
+    case tokPunct:
+        switch tok.text {
+        case "(":
+            p.next()
+            inner, err := p.parseOr()
+            if err != nil {
+                return nil, err
+            }
+            if !p.tok.is(tokPunct, ")") {
+                return nil, p.errorf("expected )")
+            }
+            p.next()
+            return inner, p.err
+        case "[":
+            return p.parseList()
+        }
+    case tokIdent:
+        switch tok.text {
+        case "true", "false":
+            p.next()
+            return literal{tok.text == "true"}, p.err
+        case "null":
+            p.next()
+            return literal{nil}, p.err
+        case "in":
+            return nil, p.errorf("unexpected in")
+        }
+        return p.parsePath()
+    }
+    if p.err != nil {
+        return nil, p.err
+    }
+    return nil, p.errorf("unexpected %s", tok)
+}
+
+func (p *parser) parseList() (node, error) {
+    p.next() // [
+    var list listNode
+    for !p.tok.is(tokPunct, "]") {
+        if len(list) > 0 {
+            if !p.tok.is(tokPunct, ",") {
+                return nil, p.errorf("expected , or ]")
+            }
+            p.next()
+        }
+        item, err := p.parseOperand()
+        if err != nil {
+            return nil, err
+        }
+        list = append(list, item)
+    }
+    p.next()
+    return list, p.err
+}
+
+func (p *parser) parsePath() (node, error) {
+    path := pathNode{p.tok.text}
+    p.next()
+    for {
+        switch {
+        case p.tok.is(tokPunct, "."):
+            p.next()
+            if p.tok.kind != tokIdent {
+                return nil, p.errorf("expected field name after .")
+            }
+            path = append(path, p.tok.text)
+            p.next()
+        case p.tok.is(tokPunct, "["):
+            p.next()
+            switch p.tok.kind {
+            case tokString:
+                path = append(path, p.tok.text)
+            case tokNumber:
+                i, err := strconv.Atoi(p.tok.text)
+                if err != nil || i < 0 {
+                    return nil, p.errorf("invalid index %s", p.tok.text)
+                }
+                path = append(path, i)
+            default:
+                return nil, p.errorf("expected index or quoted key")
+            }
+            p.next()
+            if !p.tok.is(tokPunct, "]") {
+                return nil, p.errorf("expected ]")
+            }
+            p.next()
+        default:
+            return path, p.err
+        }
+    }
+}
+
+type tokenKind int
+
+const (
+    tokEOF tokenKind = iota
+    tokIdent
+    tokNumber
+    tokString
+    tokOp
+    tokPunct
+)
+
+type token struct {
+    kind tokenKind
+    text string
+    pos  int
+}
+
+func (t token) is(kind tokenKind, text string) bool {
+    return t.kind == kind && t.text == text
+}
+
+func (t token) String() string {
+    switch t.kind {
+    case tokEOF:
+        return "end of expression"
+    case tokString:
+        return strconv.Quote(t.text)
+    }
+    return fmt.Sprintf("%q", t.text)
+}
+
+type lexer struct {
+    src string
+    pos int
+}
+
+func (l *lexer) next() (token, error) {
+    for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+        l.pos++
+    }
+    start := l.pos
+    if l.pos >= len(l.src) {
+        return token{kind: tokEOF, pos: start}, nil
+    }
+    c := l.src[l.pos]
+    switch {
+    case c == '"' || c == '\'':
+        return l.lexString(c)
+    case c >= '0' && c <= '9' || c == '-' && l.pos+1 < len(l.src) && l.src[l.pos+1] >= '0' && l.src[l.pos+1] <= '9':
+        l.pos++
+        for l.pos < len(l.src) && strings.IndexByte("0123456789.eE+-", l.src[l.pos]) >= 0 {
+            l.pos++
+        }
+        return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+    case c == '_' || unicode.IsLetter(rune(c)):
+        for l.pos < len(l.src) && (l.src[l.pos] == '_' || unicode.IsLetter(rune(l.src[l.pos])) || unicode.IsDigit(rune(l.src[l.pos]))) {
+            l.pos++
+        }
+        return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+    case strings.IndexByte(".[](),", c) >= 0:
+        l.pos++
+        return token{kind: tokPunct, text: string(c), pos: start}, nil
+    }
+    for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!"} {
+        if strings.HasPrefix(l.src[l.pos:], op) {
+            l.pos += len(op)
+            return token{kind: tokOp, text: op, pos: start}, nil
+        }
+    }
+    return token{}, fmt.Errorf("filter: unexpected character %q at position %d", c, start+1)
+}
+
+func (l *lexer) lexString(quote byte) (token, error) {
+    start := l.pos
+    l.pos++
+    var b strings.Builder
+    for l.pos < len(l.src) {
+        c := l.src[l.pos]
+        switch {
+        case c == quote:
+            l.pos++
+            return token{kind: tokString, text: b.String(), pos: start}, nil
+        case c == '\\' && l.pos+1 < len(l.src):
+            l.pos++
+            switch e := l.src[l.pos]; e {
+            case 'n':
+                b.WriteByte('\n')
+            case 't':
+                b.WriteByte('\t')
+            default:
+                b.WriteByte(e)
+            }
+        default:
+            b.WriteByte(c)
+        }
+        l.pos++
+    }
+    return token{}, fmt.Errorf("filter: unterminated string at position %d", start+1)
+}
@@ -0,0 +1,101 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotConfigured is returned when secret material is stored or read
+// without SECRETS_ENCRYPTION_KEY set.
+var ErrNotConfigured = errors.New("SECRETS_ENCRYPTION_KEY is not configured")
+
+// prefix versions the ciphertext format so the scheme can change later.
+const prefix = "v1:"
+
+// Box encrypts values at rest with AES-256-GCM.
+type Box struct {
+    aead cipher.AEAD
+}
+
+// NewBoxFromEnv builds a Box from SECRETS_ENCRYPTION_KEY, a 32-byte key
+// given as base64 or hex. It returns a nil Box when the variable is unset.
+func NewBoxFromEnv() (*Box, error) {
+    v := strings.TrimSpace(os.Getenv("SECRETS_ENCRYPTION_KEY"))
+    if v == "" {
+        return nil, nil
+    }
+    key, err := decodeKey(v)
+    if err != nil {
+        return nil, err
+    }
+    return NewBox(key)
+}
+
+// NewBox builds a Box from a raw 32-byte key.
+func NewBox(key []byte) (*Box, error) {
+    if len(key) != 32 {
+        return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    aead, err := cipher.NewGCM(block)
+    if err != nil {
+        return nil, err
+    }
+    return &Box{aead: aead}, nil
+}
+
+func decodeKey(v string) ([]byte, error) {
+    if key, err := hex.DecodeString(v); err == nil && len(key) == 32 {
+        return key, nil
+    }
+    if key, err := base64.StdEncoding.DecodeString(v); err == nil && len(key) == 32 {
+        return key, nil
+    }
+    return nil, errors.New("SECRETS_ENCRYPTION_KEY must be 32 bytes encoded as hex or base64")
+}
+
+// Encrypt seals plaintext and returns it as printable text.
+func (b *Box) Encrypt(plaintext string) (string, error) {
+    if b == nil {
+        return "", ErrNotConfigured
+    }
+    nonce := make([]byte, b.aead.NonceSize())
+    if _, err := rand.Read(nonce); err != nil {
+        return "", err
+    }
+    sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+    return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt.
+func (b *Box) Decrypt(ciphertext string) (string, error) {
+    if b == nil {
+        return "", ErrNotConfigured
+    }
+    if !strings.HasPrefix(ciphertext, prefix) {
+        return "", errors.New("unrecognised ciphertext format")
+    }
+    sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, prefix))
+    if err != nil {
+        return "", err
+    }
+    nonceSize := b.aead.NonceSize()
+    if len(sealed) < nonceSize {
+        return "", errors.New("ciphertext too short")
+    }
+    plaintext, err := b.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+    if err != nil {
+        return "", fmt.Errorf("decrypting secret: %w", err)
+    }
+    return string(plaintext), nil
+}
@@ -1,27 +1,126 @@
--- name: ListPendingDeliveryTasks :many
-SELECT * FROM delivery_tasks
-WHERE status = 'pending' AND (next_attempt_at IS NULL OR next_attempt_at <= CURRENT_TIMESTAMP)
-ORDER BY created_at ASC
-LIMIT 10;
+-- name: ClaimDeliveryTasks :many
+-- Claims up to batch_size due tasks, taking no more per subscription than
+-- max_per_subscription minus what is already in flight for it. A task with an
+-- ordering key is only claimable once every earlier task in its stream has
+-- been delivered or dead-lettered. Batching subscriptions are claimed by
+-- ClaimDeliveryBatch instead.
+UPDATE delivery_tasks
+SET status = 'in_flight', lease_owner = sqlc.arg(lease_owner), lease_expires_at = sqlc.arg(lease_expires_at)
+WHERE id IN (
+    SELECT candidate.id FROM (
+        SELECT
+            t.id,
+            t.created_at,
+            ROW_NUMBER() OVER (PARTITION BY t.subscription_id ORDER BY t.created_at) AS position,
+            (
+                SELECT COUNT(*) FROM delivery_tasks busy
+                WHERE busy.subscription_id = t.subscription_id
+                  AND busy.status = 'in_flight'
+                  AND busy.lease_expires_at > sqlc.arg(now)
+            ) AS busy_count
+        FROM delivery_tasks t
+        WHERE (
+            (t.status = 'pending' AND (t.next_attempt_at IS NULL OR t.next_attempt_at <= sqlc.arg(now)))
+            OR (t.status = 'in_flight' AND t.lease_expires_at <= sqlc.arg(now))
+        )
+        AND (
+            t.ordering_key IS NULL OR NOT EXISTS (
+                SELECT 1 FROM delivery_tasks earlier
+                WHERE earlier.subscription_id = t.subscription_id
+                  AND earlier.ordering_key = t.ordering_key
+                  AND earlier.status IN ('pending', 'in_flight')
+                  AND (earlier.created_at < t.created_at
+                       OR (earlier.created_at = t.created_at AND earlier.rowid < t.rowid))
+            )
+        )
+        AND NOT EXISTS (
+            SELECT 1 FROM subscriptions s
+            WHERE s.id = t.subscription_id AND s.batch_max_size > 1
+        )
+    ) candidate
+    WHERE candidate.position + candidate.busy_count <= sqlc.arg(max_per_subscription)
+    ORDER BY candidate.created_at ASC
+    LIMIT sqlc.arg(batch_size)
+)
+RETURNING *;
+
+-- name: ClaimDeliveryBatch :many
+-- Claims the next batch of a batching subscription: up to batch_size due
+-- tasks, once there are batch_size of them or the oldest was created before
+-- ready_before. Nothing is claimed while a batch of the subscription is still
+-- in flight.
+UPDATE delivery_tasks
+SET status = 'in_flight', lease_owner = sqlc.arg(lease_owner), lease_expires_at = sqlc.arg(lease_expires_at)
+WHERE id IN (
+    SELECT due.id FROM (
+        SELECT
+            t.id,
+            t.created_at,
+            t.rowid AS seq,
+            COUNT(*) OVER () AS due_count,
+            MIN(t.created_at) OVER () AS oldest_created_at
+        FROM delivery_tasks t
+        WHERE t.subscription_id = sqlc.arg(subscription_id)
+          AND (
+              (t.status = 'pending' AND (t.next_attempt_at IS NULL OR t.next_attempt_at <= sqlc.arg(now)))
+              OR (t.status = 'in_flight' AND t.lease_expires_at <= sqlc.arg(now))
+          )
+          AND (
+              t.ordering_key IS NULL OR NOT EXISTS (
+                  SELECT 1 FROM delivery_tasks earlier
+                  WHERE earlier.subscription_id = t.subscription_id
+                    AND earlier.ordering_key = t.ordering_key
+                    AND earlier.status IN ('pending', 'in_flight')
+                    AND (earlier.created_at < t.created_at
+                         OR (earlier.created_at = t.created_at AND earlier.rowid < t.rowid))
+              )
+          )
+          AND NOT EXISTS (
+              SELECT 1 FROM delivery_tasks busy
+              WHERE busy.subscription_id = t.subscription_id
+                AND busy.status = 'in_flight'
+                AND busy.lease_expires_at > sqlc.arg(now)
+          )
+    ) due
+    WHERE due.due_count >= sqlc.arg(batch_size)
+       OR datetime(due.oldest_created_at) <= datetime(sqlc.arg(ready_before))
+    ORDER BY due.created_at ASC, due.seq ASC
+    LIMIT sqlc.arg(batch_size)
+)
+RETURNING *;
 
 -- name: CreateDeliveryTask :exec
-INSERT INTO delivery_tasks (id, subscription_id, payload, status, attempt_count, created_at)
-VALUES (?, ?, ?, 'pending', 0, CURRENT_TIMESTAMP);
+INSERT INTO delivery_tasks (id, subscription_id, payload, ordering_key, event_type, event_id, status, attempt_count, created_at)
+VALUES (?, ?, ?, ?, ?, ?, 'pending', 0, CURRENT_TIMESTAMP);
 
--- name: UpdateDeliveryTaskStatus :exec
+-- name: CreateFilteredDeliveryTask :exec
+-- Records an event the subscription's filters rejected. It is never claimed.
+INSERT INTO delivery_tasks (id, subscription_id, payload, event_type, event_id, status, attempt_count, created_at)
+VALUES (?, ?, ?, ?, ?, 'filtered', 0, CURRENT_TIMESTAMP);
+
+-- name: UpdateDeliveryTaskStatus :execrows
+-- Records an attempt's outcome, schedules the next one and releases the
+-- lease, all only while the caller still holds the lease.
 UPDATE delivery_tasks
-SET status = ?, last_attempt_at = ?, attempt_count = ?
-WHERE id = ?;
+SET status = ?, last_attempt_at = ?, attempt_count = ?, next_attempt_at = ?, lease_owner = NULL, lease_expires_at = NULL
+WHERE id = ? AND lease_owner = ?;
 
 -- name: CreateDeliveryLog :exec
 INSERT INTO delivery_logs (
     id, delivery_task_id, subscription_id, target_url, timestamp,
-    attempt_number, outcome, http_status, error_details
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+    attempt_number, outcome, http_status, error_details, signature_scheme,
+    classification, batch_id, latency_ms, response_body, response_headers,
+    dns_ms, connect_ms, tls_ms, ttfb_ms, connection_reused
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: GetDeliveryTask :one
 SELECT * FROM delivery_tasks WHERE id = ?;
 
+-- name: ListDeliveryTasksForEvent :many
+SELECT * FROM delivery_tasks
+WHERE event_id = ?
+ORDER BY created_at ASC, rowid ASC;
+
 -- name: ListDeliveryLogsForTask :many
 SELECT * FROM delivery_logs
 WHERE delivery_task_id = ?
@@ -38,6 +137,10 @@ SELECT
     dl.outcome,
     dl.http_status,
     dl.error_details,
+    dl.classification,
+    dl.latency_ms,
+    dl.response_body,
+    dl.response_headers,
     dt.status AS task_status
 FROM delivery_logs dl
 LEFT JOIN delivery_tasks dt ON dl.delivery_task_id = dt.id
@@ -49,7 +152,8 @@ LIMIT 20;
 DELETE FROM delivery_logs
 WHERE timestamp < datetime('now', '-72 hours');
 
--- name: UpdateDeliveryTaskNextAttemptAt :exec
+-- name: DeferDeliveryTask :execrows
+-- Hands a claimed task back to the queue without counting an attempt.
 UPDATE delivery_tasks
-SET next_attempt_at = ?
-WHERE id = ?;
\ No newline at end of file
+SET status = 'pending', next_attempt_at = ?, lease_owner = NULL, lease_expires_at = NULL
+WHERE id = ? AND lease_owner = ?;
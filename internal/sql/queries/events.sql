@@ -0,0 +1,13 @@
+-- name: CreateEvent :exec
+INSERT INTO events (id, event_type, payload, source, headers, received_at)
+VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP);
+
+-- name: GetEvent :one
+SELECT * FROM events WHERE id = ?;
+
+-- name: ListEvents :many
+-- Newest first; an empty event_type lists every type.
+SELECT * FROM events
+WHERE (sqlc.arg(event_type) = '' OR event_type = sqlc.arg(event_type))
+ORDER BY received_at DESC, rowid DESC
+LIMIT ? OFFSET ?;
@@ -1,18 +1,52 @@
 -- name: CreateSubscription :exec
-INSERT INTO subscriptions (id, target_url, secret, event_types)
-VALUES (?, ?, ?, ?);
+INSERT INTO subscriptions (
+    id, target_url, secret, event_types,
+    max_attempts, retry_base_delay_seconds, retry_max_delay_seconds, retry_multiplier, retry_jitter, retry_schedule,
+    rate_limit_per_second, rate_limit_burst, ordered, batch_max_size, batch_max_wait_seconds,
+    headers, transform, filter, signing_profile, replay_protection, verification_scheme
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: UpdateSubscription :exec
 UPDATE subscriptions
-SET target_url = ?, secret = ?, event_types = ?
+SET target_url = ?, secret = ?, event_types = ?,
+    max_attempts = ?, retry_base_delay_seconds = ?, retry_max_delay_seconds = ?,
+    retry_multiplier = ?, retry_jitter = ?, retry_schedule = ?,
+    rate_limit_per_second = ?, rate_limit_burst = ?, ordered = ?,
+    batch_max_size = ?, batch_max_wait_seconds = ?, headers = ?, transform = ?, filter = ?,
+    signing_profile = ?, replay_protection = ?, verification_scheme = ?
 WHERE id = ?;
 
 -- name: GetSubscription :one
 SELECT * FROM subscriptions WHERE id = ?;
 
 -- name: ListSubscriptions :many
-SELECT id, target_url, secret, created_at, updated_at, event_types FROM subscriptions;
+SELECT * FROM subscriptions;
+
+-- name: ListBatchingSubscriptions :many
+SELECT * FROM subscriptions WHERE batch_max_size > 1;
 
 -- name: DeleteSubscription :exec
 DELETE FROM subscriptions WHERE id = ?;
 
+-- name: UpdateSubscriptionTLS :exec
+UPDATE subscriptions
+SET tls_client_cert = ?, tls_client_key_encrypted = ?, tls_ca_bundle = ?, tls_pinned_cert_sha256 = ?
+WHERE id = ?;
+
+-- name: UpdateSubscriptionAuth :exec
+UPDATE subscriptions
+SET auth_type = ?, auth_config_encrypted = ?
+WHERE id = ?;
+
+-- name: RotateSubscriptionSecret :exec
+UPDATE subscriptions
+SET previous_secret = secret, previous_secret_expires_at = ?, secret = ?
+WHERE id = ?;
+
+-- name: CreateSecretRotation :exec
+INSERT INTO secret_rotations (id, subscription_id, previous_secret_expires_at)
+VALUES (?, ?, ?);
+
+-- name: ListSecretRotations :many
+SELECT * FROM secret_rotations WHERE subscription_id = ? ORDER BY rotated_at DESC, rowid DESC;
@@ -0,0 +1,5 @@
+-- +goose up
+ALTER TABLE delivery_logs ADD COLUMN signature_scheme TEXT; -- none, hmac-sha256
+
+-- +goose down
+ALTER TABLE delivery_logs DROP COLUMN signature_scheme;
@@ -0,0 +1,12 @@
+-- +goose up
+-- A worker claims a task by moving it to 'in_flight' and stamping a lease.
+-- Tasks whose lease has expired (e.g. the worker crashed) can be claimed again.
+ALTER TABLE delivery_tasks ADD COLUMN lease_owner TEXT;
+ALTER TABLE delivery_tasks ADD COLUMN lease_expires_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_delivery_tasks_status ON delivery_tasks(status);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_delivery_tasks_status;
+ALTER TABLE delivery_tasks DROP COLUMN lease_expires_at;
+ALTER TABLE delivery_tasks DROP COLUMN lease_owner;
@@ -0,0 +1,17 @@
+-- +goose up
+-- Per-subscription retry policy. NULL columns fall back to the service defaults
+-- (5 attempts, backoff of 10s, 30s, 1m, 5m, 15m).
+ALTER TABLE subscriptions ADD COLUMN max_attempts INTEGER;
+ALTER TABLE subscriptions ADD COLUMN retry_base_delay_seconds INTEGER;
+ALTER TABLE subscriptions ADD COLUMN retry_max_delay_seconds INTEGER;
+ALTER TABLE subscriptions ADD COLUMN retry_multiplier REAL;
+ALTER TABLE subscriptions ADD COLUMN retry_jitter REAL; -- fraction of the delay, 0 to 1
+ALTER TABLE subscriptions ADD COLUMN retry_schedule TEXT; -- comma-separated durations, e.g. 10s,1m,10m
+
+-- +goose down
+ALTER TABLE subscriptions DROP COLUMN retry_schedule;
+ALTER TABLE subscriptions DROP COLUMN retry_jitter;
+ALTER TABLE subscriptions DROP COLUMN retry_multiplier;
+ALTER TABLE subscriptions DROP COLUMN retry_max_delay_seconds;
+ALTER TABLE subscriptions DROP COLUMN retry_base_delay_seconds;
+ALTER TABLE subscriptions DROP COLUMN max_attempts;
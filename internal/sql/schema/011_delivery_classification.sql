@@ -0,0 +1,5 @@
+-- +goose up
+ALTER TABLE delivery_logs ADD COLUMN classification TEXT; -- success, retryable, throttled, permanent
+
+-- +goose down
+ALTER TABLE delivery_logs DROP COLUMN classification;
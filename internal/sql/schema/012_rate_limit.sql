@@ -0,0 +1,8 @@
+-- +goose up
+-- Outbound token bucket per subscription. NULL rate means unlimited.
+ALTER TABLE subscriptions ADD COLUMN rate_limit_per_second REAL;
+ALTER TABLE subscriptions ADD COLUMN rate_limit_burst INTEGER;
+
+-- +goose down
+ALTER TABLE subscriptions DROP COLUMN rate_limit_burst;
+ALTER TABLE subscriptions DROP COLUMN rate_limit_per_second;
@@ -0,0 +1,12 @@
+-- +goose up
+-- Ordered subscriptions deliver each stream strictly one task at a time.
+-- ordering_key names the stream a task belongs to; NULL means unordered.
+ALTER TABLE subscriptions ADD COLUMN ordered BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE delivery_tasks ADD COLUMN ordering_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_delivery_tasks_ordering ON delivery_tasks(subscription_id, ordering_key, status);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_delivery_tasks_ordering;
+ALTER TABLE delivery_tasks DROP COLUMN ordering_key;
+ALTER TABLE subscriptions DROP COLUMN ordered;
@@ -0,0 +1,13 @@
+-- +goose up
+-- Subscriptions with batch_max_size > 1 receive their tasks grouped into a
+-- single request of up to batch_max_size events, sent once the batch is full
+-- or its oldest event has waited batch_max_wait_seconds.
+ALTER TABLE subscriptions ADD COLUMN batch_max_size INTEGER;
+ALTER TABLE subscriptions ADD COLUMN batch_max_wait_seconds INTEGER;
+-- Attempts that went out in the same request share a batch_id.
+ALTER TABLE delivery_logs ADD COLUMN batch_id TEXT;
+
+-- +goose down
+ALTER TABLE delivery_logs DROP COLUMN batch_id;
+ALTER TABLE subscriptions DROP COLUMN batch_max_wait_seconds;
+ALTER TABLE subscriptions DROP COLUMN batch_max_size;
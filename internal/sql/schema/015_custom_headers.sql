@@ -0,0 +1,10 @@
+-- +goose up
+-- Extra request headers sent with every delivery, as a JSON object of
+-- header name to value. Values may be Go templates.
+ALTER TABLE subscriptions ADD COLUMN headers TEXT;
+-- The X-Event-Type the task was ingested with.
+ALTER TABLE delivery_tasks ADD COLUMN event_type TEXT;
+
+-- +goose down
+ALTER TABLE delivery_tasks DROP COLUMN event_type;
+ALTER TABLE subscriptions DROP COLUMN headers;
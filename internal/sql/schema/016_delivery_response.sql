@@ -0,0 +1,12 @@
+-- +goose up
+-- What the target sent back on each attempt. response_body is truncated to
+-- DELIVERY_RESPONSE_BODY_LIMIT bytes; response_headers is a JSON object of
+-- the headers listed in DELIVERY_CAPTURED_RESPONSE_HEADERS.
+ALTER TABLE delivery_logs ADD COLUMN latency_ms INTEGER;
+ALTER TABLE delivery_logs ADD COLUMN response_body TEXT;
+ALTER TABLE delivery_logs ADD COLUMN response_headers TEXT;
+
+-- +goose down
+ALTER TABLE delivery_logs DROP COLUMN response_headers;
+ALTER TABLE delivery_logs DROP COLUMN response_body;
+ALTER TABLE delivery_logs DROP COLUMN latency_ms;
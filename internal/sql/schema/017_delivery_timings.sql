@@ -0,0 +1,15 @@
+-- +goose up
+-- Per-phase timings of each attempt, in milliseconds. A phase that did not
+-- happen (e.g. DNS and connect on a reused connection) is NULL.
+ALTER TABLE delivery_logs ADD COLUMN dns_ms INTEGER;
+ALTER TABLE delivery_logs ADD COLUMN connect_ms INTEGER;
+ALTER TABLE delivery_logs ADD COLUMN tls_ms INTEGER;
+ALTER TABLE delivery_logs ADD COLUMN ttfb_ms INTEGER;
+ALTER TABLE delivery_logs ADD COLUMN connection_reused BOOLEAN;
+
+-- +goose down
+ALTER TABLE delivery_logs DROP COLUMN connection_reused;
+ALTER TABLE delivery_logs DROP COLUMN ttfb_ms;
+ALTER TABLE delivery_logs DROP COLUMN tls_ms;
+ALTER TABLE delivery_logs DROP COLUMN connect_ms;
+ALTER TABLE delivery_logs DROP COLUMN dns_ms;
@@ -0,0 +1,15 @@
+-- +goose up
+-- Client certificate for mutual TLS. The private key is encrypted with
+-- SECRETS_ENCRYPTION_KEY; the certificate and CA bundle are public PEM.
+-- tls_pinned_cert_sha256 is the hex SHA-256 of the expected server leaf
+-- certificate.
+ALTER TABLE subscriptions ADD COLUMN tls_client_cert TEXT;
+ALTER TABLE subscriptions ADD COLUMN tls_client_key_encrypted TEXT;
+ALTER TABLE subscriptions ADD COLUMN tls_ca_bundle TEXT;
+ALTER TABLE subscriptions ADD COLUMN tls_pinned_cert_sha256 TEXT;
+
+-- +goose down
+ALTER TABLE subscriptions DROP COLUMN tls_pinned_cert_sha256;
+ALTER TABLE subscriptions DROP COLUMN tls_ca_bundle;
+ALTER TABLE subscriptions DROP COLUMN tls_client_key_encrypted;
+ALTER TABLE subscriptions DROP COLUMN tls_client_cert;
@@ -0,0 +1,9 @@
+-- +goose up
+-- Outbound authentication: basic, bearer or oauth2 (client credentials).
+-- The credentials are a JSON document encrypted with SECRETS_ENCRYPTION_KEY.
+ALTER TABLE subscriptions ADD COLUMN auth_type TEXT;
+ALTER TABLE subscriptions ADD COLUMN auth_config_encrypted TEXT;
+
+-- +goose down
+ALTER TABLE subscriptions DROP COLUMN auth_config_encrypted;
+ALTER TABLE subscriptions DROP COLUMN auth_type;
@@ -0,0 +1,7 @@
+-- +goose up
+-- JSON template the payload is reshaped with before delivery; see
+-- internal/transform. NULL delivers the payload unchanged.
+ALTER TABLE subscriptions ADD COLUMN transform TEXT;
+
+-- +goose down
+ALTER TABLE subscriptions DROP COLUMN transform;
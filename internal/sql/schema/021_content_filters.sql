@@ -0,0 +1,7 @@
+-- +goose up
+-- Expression over the JSON payload that events must match to be delivered,
+-- e.g. amount > 1000 && currency == "USD". See internal/filter.
+ALTER TABLE subscriptions ADD COLUMN filter TEXT;
+
+-- +goose down
+ALTER TABLE subscriptions DROP COLUMN filter;
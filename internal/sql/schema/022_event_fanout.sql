@@ -0,0 +1,8 @@
+-- +goose up
+-- Tasks created by POST /events share the ID of the event they came from.
+ALTER TABLE delivery_tasks ADD COLUMN event_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_delivery_tasks_event ON delivery_tasks(event_id);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_delivery_tasks_event;
+ALTER TABLE delivery_tasks DROP COLUMN event_id;
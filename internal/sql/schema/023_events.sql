@@ -0,0 +1,18 @@
+-- +goose up
+-- Every ingested event, independent of the tasks it produced. Tasks point
+-- here through delivery_tasks.event_id.
+CREATE TABLE IF NOT EXISTS events (
+    id TEXT PRIMARY KEY,
+    event_type TEXT,
+    payload TEXT NOT NULL,
+    source TEXT NOT NULL, -- ingest, events, scheduled, or X-Event-Source
+    headers TEXT, -- JSON object of the request headers, credentials removed
+    received_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_events_received_at ON events(received_at);
+CREATE INDEX IF NOT EXISTS idx_events_event_type ON events(event_type, received_at);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_events_event_type;
+DROP INDEX IF EXISTS idx_events_received_at;
+DROP TABLE IF EXISTS events;
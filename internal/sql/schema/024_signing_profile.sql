@@ -0,0 +1,7 @@
+-- +goose up
+-- How deliveries are signed: hmac-sha256 (X-Hub-Signature-256) or
+-- standard-webhooks (webhook-id / webhook-timestamp / webhook-signature).
+ALTER TABLE subscriptions ADD COLUMN signing_profile TEXT NOT NULL DEFAULT 'hmac-sha256';
+
+-- +goose down
+ALTER TABLE subscriptions DROP COLUMN signing_profile;
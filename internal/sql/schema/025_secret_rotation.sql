@@ -0,0 +1,20 @@
+-- +goose up
+-- The secret replaced by the last rotation stays valid, for ingest
+-- verification and as an extra outbound signature, until it expires.
+ALTER TABLE subscriptions ADD COLUMN previous_secret TEXT;
+ALTER TABLE subscriptions ADD COLUMN previous_secret_expires_at DATETIME;
+
+-- One row per rotation. Secret values are never stored here.
+CREATE TABLE IF NOT EXISTS secret_rotations (
+    id TEXT PRIMARY KEY,
+    subscription_id TEXT NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    rotated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    previous_secret_expires_at DATETIME -- NULL when there was no secret before
+);
+CREATE INDEX IF NOT EXISTS idx_secret_rotations_subscription ON secret_rotations(subscription_id, rotated_at);
+
+-- +goose down
+DROP INDEX IF EXISTS idx_secret_rotations_subscription;
+DROP TABLE IF EXISTS secret_rotations;
+ALTER TABLE subscriptions DROP COLUMN previous_secret_expires_at;
+ALTER TABLE subscriptions DROP COLUMN previous_secret;
@@ -0,0 +1,7 @@
+-- +goose up
+-- When set, ingested requests must sign X-Webhook-Timestamp together with
+-- the body, fall inside the tolerance window and not reuse a signature.
+ALTER TABLE subscriptions ADD COLUMN replay_protection BOOLEAN NOT NULL DEFAULT 0;
+
+-- +goose down
+ALTER TABLE subscriptions DROP COLUMN replay_protection;
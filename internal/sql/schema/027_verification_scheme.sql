@@ -0,0 +1,7 @@
+-- +goose up
+-- Which signature scheme IngestWebhook checks: hmac-sha256 (the service's
+-- own X-Hub-Signature-256), github, stripe, shopify or slack.
+ALTER TABLE subscriptions ADD COLUMN verification_scheme TEXT NOT NULL DEFAULT 'hmac-sha256';
+
+-- +goose down
+ALTER TABLE subscriptions DROP COLUMN verification_scheme;
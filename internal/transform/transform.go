@@ -0,0 +1,222 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segment is one step of a path: an object key, or an array index when
+// key is empty.
+type segment struct {
+    key   string
+    index int
+}
+
+type path struct {
+    expr     string
+    segments []segment
+    optional bool
+}
+
+// Validate checks that spec is valid JSON and every path in it parses.
+func Validate(spec string) error {
+    tmpl, err := decode([]byte(spec))
+    if err != nil {
+        return fmt.Errorf("transform is not valid JSON: %w", err)
+    }
+    return walk(tmpl, "", func(s, at string) error {
+        if _, err := parsePath(s); err != nil {
+            return locate(at, err)
+        }
+        return nil
+    })
+}
+
+// Apply renders spec against payload and returns the JSON to deliver. An
+// empty spec returns payload unchanged.
+//
+// A spec is a JSON template for the delivered body. Strings starting with
+// "$" are replaced by the value at that path in the payload:
+//
+//	"$"                  the whole payload
+//	"$.order.id"         a field
+//	"$.items[0].sku"     an array element
+//	"$.customer.email?"  optional: null instead of an error when missing
+//
+// Everything else is copied as is, so {"data": "$", "version": 1} wraps the
+// payload in an envelope and {"id": "$.order.id"} keeps one renamed field.
+// A literal string starting with "$" is written as "$$...".
+func Apply(spec string, payload []byte) ([]byte, error) {
+    if strings.TrimSpace(spec) == "" {
+        return payload, nil
+    }
+    tmpl, err := decode([]byte(spec))
+    if err != nil {
+        return nil, fmt.Errorf("transform is not valid JSON: %w", err)
+    }
+    doc, err := decode(payload)
+    if err != nil {
+        return nil, errors.New("payload is not valid JSON")
+    }
+    out, err := render(tmpl, doc, "")
+    if err != nil {
+        return nil, err
+    }
+    return json.Marshal(out)
+}
+
+func decode(data []byte) (interface{}, error) {
+    dec := json.NewDecoder(bytes.NewReader(data))
+    dec.UseNumber()
+    var v interface{}
+    if err := dec.Decode(&v); err != nil {
+        return nil, err
+    }
+    if dec.More() {
+        return nil, errors.New("unexpected data after top-level value")
+    }
+    return v, nil
+}
+
+// walk calls fn for every path string in tmpl, with at locating it in the
+// spec for error messages.
+func walk(tmpl interface{}, at string, fn func(s, at string) error) error {
+    switch n := tmpl.(type) {
+    case string:
+        if strings.HasPrefix(n, "$") && !strings.HasPrefix(n, "$$") {
+            return fn(n, at)
+        }
+    case map[string]interface{}:
+        for k, v := range n {
+            if err := walk(v, at+"."+k, fn); err != nil {
+                return err
+            }
+        }
+    case []interface{}:
+        for i, v := range n {
+            if err := walk(v, at+"["+strconv.Itoa(i)+"]", fn); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+func render(tmpl, doc interface{}, at string) (interface{}, error) {
+    switch n := tmpl.(type) {
+    case string:
+        if strings.HasPrefix(n, "$$") {
+            return n[1:], nil
+        }
+        if !strings.HasPrefix(n, "$") {
+            return n, nil
+        }
+        p, err := parsePath(n)
+        if err != nil {
+            return nil, locate(at, err)
+        }
+        v, err := p.lookup(doc)
+        if err != nil {
+            return nil, locate(at, err)
+        }
+        return v, nil
+    case map[string]interface{}:
+        out := make(map[string]interface{}, len(n))
+        for k, v := range n {
+            r, err := render(v, doc, at+"."+k)
+            if err != nil {
+                return nil, err
+            }
+            out[k] = r
+        }
+        return out, nil
+    case []interface{}:
+        out := make([]interface{}, len(n))
+        for i, v := range n {
+            r, err := render(v, doc, at+"["+strconv.Itoa(i)+"]")
+            if err != nil {
+                return nil, err
+            }
+            out[i] = r
+        }
+        return out, nil
+    default:
+        return n, nil
+    }
+}
+
+func locate(at string, err error) error {
+    if at == "" {
+        return err
+    }
+    return fmt.Errorf("at %s: %w", strings.TrimPrefix(at, "."), err)
+}
+
+func parsePath(expr string) (path, error) {
+    p := path{expr: expr}
+    rest := strings.TrimPrefix(expr, "$")
+    if strings.HasSuffix(rest, "?") {
+        p.optional = true
+        rest = strings.TrimSuffix(rest, "?")
+    }
+    for rest != "" {
+        switch rest[0] {
+        case '.':
+            rest = rest[1:]
+            end := strings.IndexAny(rest, ".[")
+            if end < 0 {
+                end = len(rest)
+            }
+            if end == 0 {
+                return p, fmt.Errorf("invalid path %q: empty field name", expr)
+            }
+            p.segments = append(p.segments, segment{key: rest[:end]})
+            rest = rest[end:]
+        case '[':
+            end := strings.IndexByte(rest, ']')
+            if end < 0 {
+                return p, fmt.Errorf("invalid path %q: missing ]", expr)
+            }
+            i, err := strconv.Atoi(rest[1:end])
+            if err != nil || i < 0 {
+                return p, fmt.Errorf("invalid path %q: bad index %q", expr, rest[1:end])
+            }
+            p.segments = append(p.segments, segment{index: i})
+            rest = rest[end+1:]
+        default:
+            return p, fmt.Errorf("invalid path %q (write $$ for a literal $)", expr)
+        }
+    }
+    return p, nil
+}
+
+func (p path) lookup(doc interface{}) (interface{}, error) {
+    cur := doc
+    for _, seg := range p.segments {
+        var ok bool
+        if seg.key != "" {
+            var obj map[string]interface{}
+            if obj, ok = cur.(map[string]interface{}); ok {
+                cur, ok = obj[seg.key]
+            }
+        } else {
+            var arr []interface{}
+            if arr, ok = cur.([]interface{}); ok && seg.index < len(arr) {
+                cur = arr[seg.index]
+            } else {
+                ok = false
+            }
+        }
+        if !ok {
+            if p.optional {
+                return nil, nil
+            }
+            return nil, fmt.Errorf("%s not found in payload", p.expr)
+        }
+    }
+    return cur, nil
+}